@@ -0,0 +1,91 @@
+package compiler
+
+import (
+	"bytes"
+	"github.com/gdtrp/brainfuck/stack"
+	"strings"
+	"testing"
+)
+
+//block operation created from functions
+type blockOperation struct {
+	command  stack.Command
+	action   func(*stack.Context) error
+	onAdd    func(*stack.Context) error
+	afterAdd func(*stack.Context) error
+}
+
+func (o blockOperation) Command() stack.Command {
+	return o.command
+}
+func (o blockOperation) Action() func(*stack.Context) error {
+	return o.action
+}
+func (o blockOperation) OnAdd() func(*stack.Context) error {
+	return o.onAdd
+}
+func (o blockOperation) AfterAdd() func(*stack.Context) error {
+	return o.afterAdd
+}
+
+func openBlock(ctx *stack.Context) error {
+	ctx.Stack.OpenBlock()
+	return nil
+}
+func closeBlock(ctx *stack.Context) error {
+	return ctx.Stack.CloseBlock()
+}
+
+//skip block if cell at offset from pointer is zero
+func skipIfZero(offset int) func(ctx *stack.Context) error {
+	return func(ctx *stack.Context) error {
+		if b, err := ctx.GetByte(ctx.CurrentIdx + offset); err != nil || b == 0 {
+			if err != nil {
+				return err
+			}
+			return ctx.Stack.SkipBlock()
+		}
+		return nil
+	}
+}
+
+var blocks = []stack.ExternalOperation{
+	//if block executed once when current cell is not zero
+	blockOperation{command: "(", onAdd: openBlock, action: skipIfZero(0)},
+	blockOperation{command: ")", afterAdd: closeBlock, action: func(*stack.Context) error { return nil }},
+	//while loop checking the next cell
+	blockOperation{command: "{", onAdd: openBlock, action: skipIfZero(1)},
+	blockOperation{command: "}", afterAdd: closeBlock, action: func(ctx *stack.Context) error {
+		return ctx.Stack.RepeatBlock()
+	}},
+}
+
+var blockScripts = []struct {
+	script string
+	result []byte
+}{
+	{"++(.+)(.)", []byte{2, 3}},
+	{"(+(.)++.)+.", []byte{1}},
+	{">+++<{+>-<}.", []byte{3}},
+	{"+++(>++>+<{.>-<})>.", []byte{2, 0}},
+	{"+(-(+.)+.[-]).", []byte{1, 0}},
+}
+
+func TestCompiler_BlockOperations(t *testing.T) {
+	c, err := New(blocks...)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	for _, test := range blockScripts {
+		var output bytes.Buffer
+		if err := c.Compile(strings.NewReader(test.script), nil, &output); err != nil {
+			t.Fatalf("%v: unexpected error %v", test.script, err)
+		}
+		if !bytes.Equal(output.Bytes(), test.result) {
+			t.Fatalf("%v: expected %v but was %v", test.script, test.result, output.Bytes())
+		}
+	}
+	if err := c.Compile(strings.NewReader("+)"), nil, &bytes.Buffer{}); err == nil {
+		t.Fatalf("missing block start error expected")
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	compiler "github.com/gdtrp/brainfuck"
+	"github.com/gdtrp/brainfuck/coverage"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+/*
+run scripts collecting coverage. every argument is a script file optionally followed by input file after "=".
+the same script can be listed many times with different inputs. script output is discarded, summary is written to stdout
+*/
+func cover(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("cover", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	lcov := flags.String("lcov", "", "write lcov tracefile to file")
+	htmlFile := flags.String("html", "", "write html report to file")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(stderr, "usage: bf cover [flags] script.bf[=input] ...")
+		return 2
+	}
+	c, err := compiler.New()
+	if err != nil {
+		fmt.Fprintln(stderr, "bf cover:", err)
+		return 1
+	}
+	collector := coverage.New(c)
+	code := 0
+	for _, arg := range flags.Args() {
+		script, input := arg, ""
+		if i := strings.LastIndex(arg, "="); i >= 0 {
+			script, input = arg[:i], arg[i+1:]
+		}
+		if err := coverScript(collector, script, input); err != nil {
+			fmt.Fprintf(stderr, "bf cover: %s: %v\n", arg, err)
+			code = 1
+		}
+	}
+	if *lcov != "" {
+		if err := writeFile(*lcov, collector.WriteLcov); err != nil {
+			fmt.Fprintln(stderr, "bf cover:", err)
+			return 1
+		}
+	}
+	if *htmlFile != "" {
+		if err := writeFile(*htmlFile, collector.WriteHTML); err != nil {
+			fmt.Fprintln(stderr, "bf cover:", err)
+			return 1
+		}
+	}
+	collector.WriteSummary(stdout)
+	return code
+}
+
+func coverScript(collector *coverage.Coverage, script string, input string) error {
+	f, err := os.Open(script)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var reader io.Reader = strings.NewReader("")
+	if input != "" {
+		in, err := os.Open(input)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		reader = in
+	}
+	return collector.Compile(script, f, reader, ioutil.Discard)
+}
+
+//create file and write its content
+func writeFile(file string, write func(io.Writer) error) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
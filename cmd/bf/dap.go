@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gdtrp/brainfuck/dap"
+	"io"
+)
+
+//run debug adapter over stdin and stdout
+func serveDap(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(args) != 0 {
+		fmt.Fprintln(stderr, "usage: bf dap")
+		return 2
+	}
+	server, err := dap.NewServer()
+	if err == nil {
+		err = server.Serve(stdin, stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "bf dap:", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	compiler "github.com/gdtrp/brainfuck"
+	"github.com/gdtrp/brainfuck/debug"
+	"io"
+	"os"
+	"strings"
+)
+
+//list of values of repeated flag
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+//run script in interactive debugger. debugger commands are read from stdin, script input is read from file
+func debugScript(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("debug", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	inputFile := flags.String("input", "", "file with script input")
+	var breakpoints stringsFlag
+	flags.Var(&breakpoints, "break", "breakpoint: LINE, LINE:COL or condition such as \"cell 3 == 10\". can be repeated")
+	run := flags.Bool("run", false, "do not pause before the first operation")
+	history := flags.Int("history", 10000, "number of recorded operations available for stepping back. 0 disables history")
+	compat := flags.String("compat", "", "compatibility profile: "+strings.Join(compiler.ProfileNames(), ", "))
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(stderr, "usage: bf debug [flags] script.bf")
+		return 2
+	}
+	c, err := compiler.New()
+	if *compat != "" {
+		p, found := compiler.LookupProfile(*compat)
+		if !found {
+			fmt.Fprintln(stderr, "bf debug: unknown profile", *compat)
+			return 2
+		}
+		err = c.SetProfile(p)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "bf debug:", err)
+		return 1
+	}
+	d := debug.New(c, debug.Console(stdin, stdout))
+	if *history > 0 {
+		d.EnableHistory(*history)
+	}
+	for _, spec := range breakpoints {
+		b, err := debug.ParseBreakpoint(spec)
+		if err != nil {
+			fmt.Fprintln(stderr, "bf debug:", err)
+			return 2
+		}
+		d.AddBreakpoint(b)
+	}
+	if *run {
+		d.Continue()
+	}
+	script, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, "bf debug:", err)
+		return 1
+	}
+	defer script.Close()
+	var input io.Reader = strings.NewReader("")
+	if *inputFile != "" {
+		f, err := os.Open(*inputFile)
+		if err != nil {
+			fmt.Fprintln(stderr, "bf debug:", err)
+			return 1
+		}
+		defer f.Close()
+		input = f
+	}
+	if err := d.Run(script, input, stdout); err != nil {
+		fmt.Fprintln(stderr, "bf debug:", err)
+		return 1
+	}
+	return 0
+}
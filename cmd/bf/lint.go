@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	compiler "github.com/gdtrp/brainfuck"
+	"io"
+	"os"
+	"strings"
+)
+
+//lintResult is a single line of json output. position file is set only for included files, script is the linted path
+type lintResult struct {
+	Script string `json:"script"`
+	compiler.Diagnostic
+}
+
+//check scripts for common mistakes. scripts are read from provided files or from stdin if no files are provided
+func lint(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	disable := flags.String("disable", "", "comma separated list of rules to skip")
+	format := flags.String("format", "text", "output format: text or json (one object per line)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	var disabled []compiler.LintRule
+	if *disable != "" {
+		for _, name := range strings.Split(*disable, ",") {
+			rule := compiler.LintRule(strings.TrimSpace(name))
+			if !compiler.IsLintRule(rule) {
+				fmt.Fprintf(stderr, "bf lint: unknown rule %q\n", rule)
+				return 2
+			}
+			disabled = append(disabled, rule)
+		}
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "bf lint: unknown format %q\n", *format)
+		return 2
+	}
+	c, err := compiler.New()
+	if err != nil {
+		fmt.Fprintln(stderr, "bf lint:", err)
+		return 1
+	}
+	files := flags.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	found := false
+	encoder := json.NewEncoder(stdout)
+	for _, file := range files {
+		diagnostics, err := lintFile(c, file, stdin, disabled)
+		if err != nil {
+			fmt.Fprintln(stderr, "bf lint:", err)
+			return 1
+		}
+		for _, d := range diagnostics {
+			found = true
+			if *format == "json" {
+				if err := encoder.Encode(lintResult{Script: file, Diagnostic: d}); err != nil {
+					fmt.Fprintln(stderr, "bf lint:", err)
+					return 1
+				}
+			} else {
+				fmt.Fprintf(stdout, "%s:%d:%d: %s: %s\n", file, d.Line, d.Column, d.Rule, d.Message)
+			}
+		}
+	}
+	if found {
+		return 1
+	}
+	return 0
+}
+
+func lintFile(c compiler.Compiler, file string, stdin io.Reader, disabled []compiler.LintRule) ([]compiler.Diagnostic, error) {
+	if file == "-" {
+		return c.Lint(stdin, disabled...)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return c.Lint(f, disabled...)
+}
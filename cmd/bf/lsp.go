@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/gdtrp/brainfuck/lsp"
+	"github.com/gdtrp/brainfuck/stack"
+	"io"
+	"os"
+	"strings"
+)
+
+//run language server over stdin and stdout
+func serveLsp(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lsp", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	commands := flags.String("commands", "", "characters of custom operations which should be treated as commands")
+	preprocessing := flags.Bool("preprocess", false, "check scripts expanded by preprocessor")
+	include := flags.String("include", "", "directories searched for included files separated by "+string(os.PathListSeparator))
+	strict := flags.Bool("strict", false, "report characters which are not commands, whitespace or comments")
+	comments := flags.String("comments", "# //", "comment markers of strict mode separated by spaces")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	server, err := lsp.NewServer()
+	if err == nil {
+		err = server.Declare(declaredCommands(*commands)...)
+	}
+	if err == nil && *strict {
+		err = server.SetStrict(true, strings.Fields(*comments)...)
+	}
+	if err == nil && *preprocessing {
+		server.SetPreprocess(true, includePaths(*include)...)
+	}
+	if err == nil {
+		err = server.Serve(stdin, stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "bf lsp:", err)
+		return 1
+	}
+	return 0
+}
+
+//split string into single character commands
+func declaredCommands(commands string) []stack.Command {
+	var result []stack.Command
+	for _, r := range commands {
+		result = append(result, stack.Command(string(r)))
+	}
+	return result
+}
@@ -0,0 +1,59 @@
+/*
+bf is a command line tool for brainfuck scripts.
+
+	bf <command> [arguments]
+
+run "bf help" to get the list of supported commands
+*/
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+//command line subcommand. returned exit code is passed to the operating system
+type command func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int
+
+var commands = map[string]command{
+	"cover": cover,
+	"dap":   serveDap,
+	"debug": debugScript,
+	"lint":  lint,
+	"lsp":   serveLsp,
+	"pipe":  pipe,
+	"run":   runScript,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" {
+		usage(stderr)
+		return 2
+	}
+	cmd, found := commands[args[0]]
+	if !found {
+		fmt.Fprintf(stderr, "bf: unknown command %q\n", args[0])
+		usage(stderr)
+		return 2
+	}
+	return cmd(args[1:], stdin, stdout, stderr)
+}
+
+func usage(writer io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(writer, "usage: bf <command> [arguments]")
+	fmt.Fprintln(writer, "commands:")
+	for _, name := range names {
+		fmt.Fprintln(writer, "\t"+name)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+//writes file to the test directory creating missing parent directories. returns path of the file
+func writeScript(t *testing.T, dir string, name string, data string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	return path
+}
+
+func TestRun_UnknownCommand(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"unknown"}, nil, &stdout, &stderr); code != 2 {
+		t.Fatalf("wrong exit code expected 2 but was %v", code)
+	}
+	if !strings.Contains(stderr.String(), "lint") {
+		t.Fatalf("usage should contain list of commands but was %v", stderr.String())
+	}
+}
+
+func TestRun_Lint(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"lint"}, strings.NewReader("+[-][-]]"), &stdout, &stderr)
+	if code != 1 {
+		t.Fatalf("wrong exit code expected 1 but was %v", code)
+	}
+	expected := "-:1:5: deadloop: loop right after closed loop never runs\n-:1:8: unbalanced: missing start loop\n"
+	if stdout.String() != expected {
+		t.Fatalf("wrong output expected %v but was %v", expected, stdout.String())
+	}
+}
+
+func TestRun_LintJson(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"lint", "-format", "json", "-disable", "deadloop"}, strings.NewReader("+[-][-]]"), &stdout, &stderr)
+	if code != 1 {
+		t.Fatalf("wrong exit code expected 1 but was %v", code)
+	}
+	expected := `{"script":"-","offset":7,"line":1,"column":8,"rule":"unbalanced","message":"missing start loop"}` + "\n"
+	if stdout.String() != expected {
+		t.Fatalf("wrong output expected %v but was %v", expected, stdout.String())
+	}
+}
+
+func TestRun_LintUnknownRule(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"lint", "-disable", "deadloop,typo"}, strings.NewReader("+"), &stdout, &stderr); code != 2 {
+		t.Fatalf("wrong exit code expected 2 but was %v", code)
+	}
+	if stderr.String() != "bf lint: unknown rule \"typo\"\n" {
+		t.Fatalf("wrong error %q", stderr.String())
+	}
+}
+
+func TestRun_Profile(t *testing.T) {
+	dir := t.TempDir()
+	script := writeScript(t, dir, "test.bf", ",[.-]")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"run", "-profile", script}, strings.NewReader("\x02"), &stdout, &stderr); code != 0 {
+		t.Fatalf("wrong exit code expected 0 but was %v: %v", code, stderr.String())
+	}
+	if stdout.String() != "\x02\x01" {
+		t.Fatalf("wrong output %q", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "        10    1 | ,[.-]\n") || !strings.Contains(stderr.String(), "1:2 ") {
+		t.Fatalf("wrong profile report %v", stderr.String())
+	}
+	stdout.Reset()
+	stderr.Reset()
+	pprof := filepath.Join(dir, "test.pprof")
+	if code := run([]string{"run", "-pprof", pprof, script}, strings.NewReader("\x01"), &stdout, &stderr); code != 0 {
+		t.Fatalf("wrong exit code expected 0 but was %v: %v", code, stderr.String())
+	}
+	if stderr.Len() != 0 {
+		t.Fatalf("report should not be written but was %v", stderr.String())
+	}
+	if data, err := ioutil.ReadFile(pprof); err != nil || len(data) == 0 {
+		t.Fatalf("pprof profile expected but was %v %v", data, err)
+	}
+}
+
+func TestRun_Cover(t *testing.T) {
+	dir := t.TempDir()
+	script := writeScript(t, dir, "test.bf", ",[-]")
+	input := writeScript(t, dir, "input", "\x01")
+	lcov := filepath.Join(dir, "lcov.info")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"cover", "-lcov", lcov, script, script + "=" + input}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("wrong exit code expected 0 but was %v: %v", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "4/4      100.0%  loops entered 1/1 skipped 1/1") {
+		t.Fatalf("wrong summary %v", stdout.String())
+	}
+	data, err := ioutil.ReadFile(lcov)
+	if err != nil || !strings.Contains(string(data), "SF:"+script+"\nBRDA:1,0,0,1\nBRDA:1,0,1,1\n") {
+		t.Fatalf("wrong lcov tracefile %s %v", data, err)
+	}
+}
+
+func TestRun_Trace(t *testing.T) {
+	dir := t.TempDir()
+	script := writeScript(t, dir, "test.bf", "++.")
+	traceFile := filepath.Join(dir, "trace.jsonl")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"run", "-trace", traceFile, "-trace-start", "2", script}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("wrong exit code expected 0 but was %v: %v", code, stderr.String())
+	}
+	data, _ := ioutil.ReadFile(traceFile)
+	expected := `{"step":2,"offset":1,"line":1,"column":2,"token":"+","pointer":0,"before":1,"after":2}` + "\n" +
+		`{"step":3,"offset":2,"line":1,"column":3,"token":".","pointer":0,"before":2,"after":2,"written":[2]}` + "\n"
+	if string(data) != expected {
+		t.Fatalf("wrong trace expected %v but was %s", expected, data)
+	}
+}
+
+func TestRun_Preprocess(t *testing.T) {
+	dir := t.TempDir()
+	writeScript(t, dir, filepath.Join("lib", "add.bf"), "#define ADD(n) {+}*n")
+	script := writeScript(t, dir, "test.bf", "#include \"lib/add.bf\"\n$ADD(3).")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"run", "-preprocess", "-include", dir, script}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("wrong exit code expected 0 but was %v: %v", code, stderr.String())
+	}
+	if !bytes.Equal(stdout.Bytes(), []byte{3}) {
+		t.Fatalf("wrong output %v", stdout.Bytes())
+	}
+}
+
+func TestRun_Strict(t *testing.T) {
+	dir := t.TempDir()
+	script := writeScript(t, dir, "test.bf", "+ // add.\n+x.")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"run", "-strict", script}, nil, &stdout, &stderr); code != 1 {
+		t.Fatalf("wrong exit code expected 1 but was %v", code)
+	}
+	if stderr.String() != "bf run: 2:2: unknown character 'x'\n" {
+		t.Fatalf("wrong error %q", stderr.String())
+	}
+}
+
+func TestRun_Compat(t *testing.T) {
+	dir := t.TempDir()
+	script := writeScript(t, dir, "test.bf", "-.")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"run", "-compat", "classic", script}, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("wrong exit code expected 0 but was %v: %v", code, stderr.String())
+	}
+	if stdout.String() != "\xff" {
+		t.Fatalf("wrong output %q", stdout.String())
+	}
+	stdout.Reset()
+	if code := run([]string{"run", "-compat", "portable", script}, nil, &stdout, &stderr); code != 1 {
+		t.Fatalf("wrong exit code expected 1 but was %v", code)
+	}
+	if stderr.String() != "bf run: cell underflow\n" {
+		t.Fatalf("wrong error %q", stderr.String())
+	}
+	stderr.Reset()
+	if code := run([]string{"run", "-compat", "unknown", script}, nil, &stdout, &stderr); code != 2 {
+		t.Fatalf("wrong exit code expected 2 but was %v", code)
+	}
+}
+
+func TestRun_DebugCompat(t *testing.T) {
+	dir := t.TempDir()
+	script := writeScript(t, dir, "test.bf", "-.")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"debug", "-run", "-compat", "portable", script}, strings.NewReader(""), &stdout, &stderr); code != 1 {
+		t.Fatalf("wrong exit code expected 1 but was %v", code)
+	}
+	if stderr.String() != "bf debug: cell underflow\n" {
+		t.Fatalf("wrong error %q", stderr.String())
+	}
+	stderr.Reset()
+	if code := run([]string{"debug", "-compat", "unknown", script}, strings.NewReader(""), &stdout, &stderr); code != 2 {
+		t.Fatalf("wrong exit code expected 2 but was %v", code)
+	}
+}
+
+func TestRun_Pipe(t *testing.T) {
+	dir := t.TempDir()
+	increment := writeScript(t, dir, "increment.bf", ",[+.,]")
+	fail := writeScript(t, dir, "fail.bf", ",.<")
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"pipe", "-compat", "wide", increment, increment}, strings.NewReader("HAL"), &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("wrong exit code expected 0 but was %v: %v", code, stderr.String())
+	}
+	if stdout.String() != "JCN" {
+		t.Fatalf("wrong output %q", stdout.String())
+	}
+	stdout.Reset()
+	if code := run([]string{"pipe", "-compat", "wide", increment, fail}, strings.NewReader("HAL"), &stdout, &stderr); code != 1 {
+		t.Fatalf("wrong exit code expected 1 but was %v", code)
+	}
+	if stderr.String() != "bf pipe: stage 2 "+fail+": index is out of range\n" {
+		t.Fatalf("wrong error %q", stderr.String())
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	compiler "github.com/gdtrp/brainfuck"
+	"io"
+	"os"
+	"strings"
+)
+
+//run scripts concurrently connecting output of every script to input of the next one. stdin is input of the first script
+func pipe(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("pipe", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	compat := flags.String("compat", "", "compatibility profile: "+strings.Join(compiler.ProfileNames(), ", "))
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(stderr, "usage: bf pipe [flags] script.bf...")
+		return 2
+	}
+	c, err := compiler.New()
+	if *compat != "" {
+		p, found := compiler.LookupProfile(*compat)
+		if !found {
+			fmt.Fprintln(stderr, "bf pipe: unknown profile", *compat)
+			return 2
+		}
+		err = c.SetProfile(p)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "bf pipe:", err)
+		return 1
+	}
+	var pipeline compiler.Pipeline
+	for _, name := range flags.Args() {
+		script, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintln(stderr, "bf pipe:", err)
+			return 1
+		}
+		defer script.Close()
+		pipeline = append(pipeline, compiler.ScriptStage(c, name, script))
+	}
+	if err := pipeline.Run(stdin, stdout); err != nil {
+		for _, e := range err.(compiler.PipelineError) {
+			fmt.Fprintln(stderr, "bf pipe:", e)
+		}
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	compiler "github.com/gdtrp/brainfuck"
+	"github.com/gdtrp/brainfuck/profile"
+	"github.com/gdtrp/brainfuck/trace"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//run script reading input from stdin. profile report is written to stderr
+func runScript(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	profiling := flags.Bool("profile", false, "write annotated listing and loop report to stderr")
+	top := flags.Int("top", 10, "number of loops in profile report. 0 reports all loops")
+	pprof := flags.String("pprof", "", "write profile in pprof format to file")
+	preprocessing := flags.Bool("preprocess", false, "expand macros, repetitions and conditional sections before execution")
+	include := flags.String("include", "", "directories searched for included files separated by "+string(os.PathListSeparator))
+	strict := flags.Bool("strict", false, "stop at characters which are not commands, whitespace or comments")
+	comments := flags.String("comments", "# //", "comment markers of strict mode separated by spaces")
+	compat := flags.String("compat", "", "compatibility profile: "+strings.Join(compiler.ProfileNames(), ", "))
+	traceFile := flags.String("trace", "", "write executed operations to file as JSON Lines")
+	var options trace.Options
+	flags.IntVar(&options.Every, "trace-every", 1, "record every n-th operation")
+	flags.IntVar(&options.Start, "trace-start", 0, "first recorded step")
+	flags.IntVar(&options.Stop, "trace-stop", 0, "last recorded step. 0 records until the end")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(stderr, "usage: bf run [flags] script.bf")
+		return 2
+	}
+	c, err := compiler.New()
+	if *compat != "" {
+		p, found := compiler.LookupProfile(*compat)
+		if !found {
+			fmt.Fprintln(stderr, "bf run: unknown profile", *compat)
+			return 2
+		}
+		err = c.SetProfile(p)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "bf run:", err)
+		return 1
+	}
+	if *strict {
+		if err := c.SetStrict(true, strings.Fields(*comments)...); err != nil {
+			fmt.Fprintln(stderr, "bf run:", err)
+			return 2
+		}
+	}
+	script, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, "bf run:", err)
+		return 1
+	}
+	defer script.Close()
+	if *include != "" {
+		c.SetIncludePath(includePaths(*include)...)
+	}
+	var source io.Reader = script
+	if *preprocessing {
+		source = c.Preprocess(script)
+	}
+	context := c.NewContext(stdin, stdout)
+	var recorder *trace.Recorder
+	if *traceFile != "" {
+		f, err := os.Create(*traceFile)
+		if err != nil {
+			fmt.Fprintln(stderr, "bf run:", err)
+			return 1
+		}
+		defer f.Close()
+		recorder = trace.New(c, f, options)
+		recorder.Attach(context)
+	}
+	var p *profile.Profiler
+	code := 0
+	if *profiling || *pprof != "" {
+		p = profile.New(c)
+		err = p.Run(source, context)
+	} else {
+		err = c.Run(source, context)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "bf run:", err)
+		code = 1
+	}
+	if recorder != nil {
+		if err := recorder.Flush(); err != nil {
+			fmt.Fprintln(stderr, "bf run:", err)
+			return 1
+		}
+	}
+	if *profiling {
+		if err := p.WriteReport(stderr, *top); err != nil {
+			fmt.Fprintln(stderr, "bf run:", err)
+			return 1
+		}
+	}
+	if *pprof != "" {
+		write := func(w io.Writer) error {
+			return p.WritePprof(w, flags.Arg(0))
+		}
+		if err := writeFile(*pprof, write); err != nil {
+			fmt.Fprintln(stderr, "bf run:", err)
+			return 1
+		}
+	}
+	return code
+}
+
+//file systems of directories in the list separated by os.PathListSeparator
+func includePaths(list string) []fs.FS {
+	var paths []fs.FS
+	for _, dir := range filepath.SplitList(list) {
+		paths = append(paths, os.DirFS(dir))
+	}
+	return paths
+}
@@ -5,10 +5,24 @@ import (
 	"fmt"
 	"github.com/gdtrp/brainfuck/stack"
 	"io"
+	"io/fs"
 )
 
 type Compiler struct {
 	commands map[stack.Command]stack.ExternalOperation
+	//script bodies of composite commands
+	composites map[stack.Command]string
+	//options checked by preprocessor conditions
+	options map[string]string
+	//file systems searched for included files
+	includes []fs.FS
+	//strict mode with comment markers
+	strict   bool
+	comments []string
+	//pragmas which script headers are allowed to override. nil allows all pragmas
+	pragmas map[string]bool
+	//profile of contexts created by the compiler. can be nil
+	profile *Profile
 }
 
 func (c *Compiler) registerOperation(operation stack.ExternalOperation) error {
@@ -20,20 +34,36 @@ func (c *Compiler) registerOperation(operation stack.ExternalOperation) error {
 }
 
 /*
-compile provided script. read byte data from reader and write outgoing bytes to writer. all unsupported tokens will be ignored
+compile provided script. read byte data from reader and write outgoing bytes to writer.
+all unsupported tokens will be ignored unless strict mode is enabled. memory is configured by the compiler profile
 */
 func (c Compiler) Compile(script io.Reader, reader io.Reader, writer io.Writer) error {
-	context := stack.NewContext(reader, writer)
-	token := make([]byte, 1)
+	return c.Run(script, c.NewContext(reader, writer))
+}
+
+/*
+compile provided script using prepared context. all unsupported tokens will be ignored unless strict mode is enabled
+*/
+func (c Compiler) Run(script io.Reader, context *stack.Context) error {
+	s, err := c.open(script, context)
+	if err != nil {
+		return err
+	}
+	return c.execute(s, context)
+}
+
+//execute tokens read by scanner
+func (c Compiler) execute(s *scanner, context *stack.Context) error {
 	for {
-		if _, err := script.Read(token); err == nil {
-			if operation, found := c.commands[stack.Command(token)]; found {
-				if err := context.Execute(operation); err != nil {
-					return err
-				}
-			} else {
+		if token, position, err := s.next(); err == nil {
+			if found, err := c.add(stack.Command(token), position, context); err != nil {
+				return err
+			} else if !found {
 				continue
 			}
+			if err := context.Resume(); err != nil {
+				return err
+			}
 		} else if err == io.EOF {
 			break
 		} else {
@@ -44,6 +74,46 @@ func (c Compiler) Compile(script io.Reader, reader io.Reader, writer io.Writer)
 	return context.ValidateExecution()
 }
 
+//Token is a command read from the script
+type Token struct {
+	stack.Position
+	Command stack.Command
+}
+
+/*
+returns commands of the script in the order Run reads them, with the same positions. header, comments of strict mode
+and unsupported tokens are skipped. commands read before the first syntax error are returned together with the error
+*/
+func (c Compiler) Tokens(script io.Reader) ([]Token, error) {
+	_, s, err := c.split(script)
+	if err != nil {
+		return nil, err
+	}
+	var tokens []Token
+	for {
+		token, position, err := s.next()
+		if err == io.EOF {
+			return tokens, nil
+		} else if err != nil {
+			return tokens, err
+		}
+		if c.IsCommand(stack.Command(token)) {
+			tokens = append(tokens, Token{Position: position, Command: stack.Command(token)})
+		}
+	}
+}
+
+/*
+returns true if token is registered as command
+*/
+func (c Compiler) IsCommand(command stack.Command) bool {
+	if _, found := c.commands[command]; found {
+		return true
+	}
+	_, found := c.composites[command]
+	return found
+}
+
 /*
 create new compiler. additional operations can also be provided. command name overlapping is not allowed
 */
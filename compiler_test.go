@@ -34,6 +34,7 @@ var scripts = []struct {
       	        ]>[-<<<<<<]>>>>
       	    ],
       	]+<++>>>[[+++++>>>>>>]<+>+[[<++++++++>-]<.<<<<<]>>>>>>>>]`, []byte("\t1\t4\t22\n"), []byte("test1 test2\ntest3 tttt")},
+	{"adjacent loops", "+[-][-]++[>+[-][-]<-]>+.", []byte{1}, nil},
 	{"simple nested loop", "++++++++++[+[>+++<-]]>.", []byte("!"), nil},
 	{"nested loop", `+++[>+++++<-]>[>+>+++>+>++>+++++>++<[++<]>---]>.>.>.`, []byte("-K-"), nil},
 	{"fibbonacci", `
@@ -0,0 +1,67 @@
+package compiler
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gdtrp/brainfuck/stack"
+)
+
+/*
+register composite command. body is a script which is expanded inline when the command is read,
+its operations get the position of the command. unsupported tokens of the body are ignored.
+recursive definitions are not allowed. compilers copied before the call are not changed
+*/
+func (c *Compiler) Define(command stack.Command, body string) error {
+	if len(command) != 1 {
+		return errors.New("command should be a single byte")
+	}
+	if c.IsCommand(command) {
+		return fmt.Errorf("operation %v already present in the supported commands list", command)
+	}
+	if path := c.recursion(command, body, nil); path != nil {
+		return fmt.Errorf("recursive definition of %v: %v", command, path)
+	}
+	composites := make(map[stack.Command]string, len(c.composites)+1)
+	for k, v := range c.composites {
+		composites[k] = v
+	}
+	composites[command] = body
+	c.composites = composites
+	return nil
+}
+
+//returns chain of composite commands leading from body to command. returns nil if command is not used
+func (c Compiler) recursion(command stack.Command, body string, path []stack.Command) []stack.Command {
+	for i := 0; i < len(body); i++ {
+		token := stack.Command(body[i])
+		if token == command {
+			return append(path, command)
+		}
+		if nested, found := c.composites[token]; found {
+			if result := c.recursion(command, nested, append(path, token)); result != nil {
+				return result
+			}
+		}
+	}
+	return nil
+}
+
+/*
+add operations of the command to the context without execution. composite commands are expanded.
+returns false if command is not registered
+*/
+func (c Compiler) add(command stack.Command, position stack.Position, context *stack.Context) (bool, error) {
+	if operation, found := c.commands[command]; found {
+		return true, context.Add(operation, position)
+	}
+	body, found := c.composites[command]
+	if !found {
+		return false, nil
+	}
+	for i := 0; i < len(body); i++ {
+		if _, err := c.add(stack.Command(body[i]), position, context); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,73 @@
+package compiler
+
+import (
+	"bytes"
+	"github.com/gdtrp/brainfuck/stack"
+	"strings"
+	"testing"
+)
+
+func TestCompiler_Define(t *testing.T) {
+	c, _ := New()
+	original := c
+	if err := c.Define("z", "[-]"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	//duplicate the cell to the right using the second cell to the right
+	if err := c.Define("d", "[->+>+<<]>>[-<<+>>]<<"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := c.Define("x", "d>.z<."); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	var output bytes.Buffer
+	if err := c.Compile(strings.NewReader("+++x>."), nil, &output); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !bytes.Equal(output.Bytes(), []byte{3, 3, 0}) {
+		t.Fatalf("wrong output %v", output.Bytes())
+	}
+	if !c.IsCommand("x") || original.IsCommand("x") {
+		t.Fatalf("composite command should be registered only in the changed compiler")
+	}
+	var positions []stack.Position
+	ctx := stack.NewContext(nil, &bytes.Buffer{})
+	ctx.BeforeAction = func(ctx *stack.Context) error {
+		positions = append(positions, ctx.Stack.Current().Position())
+		return nil
+	}
+	if err := c.Run(strings.NewReader("+\nz"), ctx); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	for _, p := range positions[1:] {
+		if p.String() != "2:1" {
+			t.Fatalf("expanded operations should have position of the command but was %v", positions)
+		}
+	}
+}
+
+func TestCompiler_DefineErrors(t *testing.T) {
+	c, _ := New()
+	for _, test := range []struct {
+		command stack.Command
+		body    string
+	}{
+		{"+", "-"},
+		{"ab", "+"},
+		{"a", "+a"},
+	} {
+		if err := c.Define(test.command, test.body); err == nil {
+			t.Fatalf("error expected for %q defined as %q", test.command, test.body)
+		}
+	}
+	if err := c.Define("a", "+b"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := c.Define("b", "c"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	err := c.Define("c", "-a")
+	if err == nil || !strings.Contains(err.Error(), "a b c") {
+		t.Fatalf("recursive definition error expected but was %v", err)
+	}
+}
@@ -0,0 +1,204 @@
+/*
+Package coverage records which parts of brainfuck scripts are executed.
+
+Coverage is collected per script file and accumulated over all runs, so a script can be run with many inputs
+and reported once. Every operation records number of executions and every loop records how many times its body
+was entered and how many times the whole loop was skipped because current cell was zero.
+*/
+package coverage
+
+import (
+	"bytes"
+	compiler "github.com/gdtrp/brainfuck"
+	"github.com/gdtrp/brainfuck/stack"
+	"io"
+	"io/ioutil"
+	"sort"
+)
+
+//Operation contains number of executions of operation at single script position
+type Operation struct {
+	stack.Position
+	Command stack.Command
+	Hits    int
+}
+
+//Loop contains number of times loop started at Start position was reached with nonzero and zero current cell
+type Loop struct {
+	Start stack.Position
+	//number of times loop body was executed at least once
+	Entered int
+	//number of times loop body was skipped
+	Skipped int
+}
+
+//File contains coverage of single script
+type File struct {
+	Name   string
+	Source []byte
+	//operations ordered by position
+	Operations []*Operation
+	//loops ordered by start position
+	Loops []*Loop
+	//operation and loop index by offset
+	operations map[int]*Operation
+	loops      map[int]*Loop
+}
+
+//returns number of operations and number of executed operations
+func (f *File) Covered() (int, int) {
+	covered := 0
+	for _, o := range f.Operations {
+		if o.Hits > 0 {
+			covered++
+		}
+	}
+	return len(f.Operations), covered
+}
+
+//Coverage runs scripts and accumulates their coverage
+type Coverage struct {
+	compiler compiler.Compiler
+	files    map[string]*File
+}
+
+/*
+create new coverage collector
+*/
+func New(c compiler.Compiler) *Coverage {
+	return &Coverage{compiler: c, files: make(map[string]*File)}
+}
+
+/*
+compile provided script collecting coverage under provided name. read byte data from reader and write outgoing bytes to writer.
+memory is configured by the compiler profile
+*/
+func (c *Coverage) Compile(name string, script io.Reader, reader io.Reader, writer io.Writer) error {
+	return c.Run(name, script, c.compiler.NewContext(reader, writer))
+}
+
+/*
+compile provided script using prepared context collecting coverage under provided name.
+runs of the same name are merged, so the script should not change between runs
+*/
+func (c *Coverage) Run(name string, script io.Reader, context *stack.Context) error {
+	var source bytes.Buffer
+	tee := compiler.WrapScript(script, io.TeeReader(script, &source))
+	hits := make(map[int]int)
+	entered := make(map[int]int)
+	skipped := make(map[int]int)
+	var previous stack.OperationalElement
+	before := context.BeforeAction
+	context.BeforeAction = func(ctx *stack.Context) error {
+		if before != nil {
+			if err := before(ctx); err != nil {
+				return err
+			}
+		}
+		element := ctx.Stack.Current()
+		offset := element.Position().Offset
+		hits[offset]++
+		//loop condition checked after closing bracket of the same loop is not a new entry
+		if element.Operation().Command() == "[" && (previous == nil || previous.Operation().Command() != "]" ||
+			previous.CurrentLoop() != element.CurrentLoop()) {
+			if ctx.GetCurrentCell() == 0 {
+				skipped[offset]++
+			} else {
+				entered[offset]++
+			}
+		}
+		previous = element
+		return nil
+	}
+	err := c.compiler.Run(tee, context)
+	//operations after failed one are not covered but still reported
+	if _, readErr := io.Copy(ioutil.Discard, tee); err == nil {
+		err = readErr
+	}
+	file, found := c.files[name]
+	if !found {
+		//operations are read from the same bytes by the compiler scanner, so they match executed positions
+		tokens, _ := c.compiler.Tokens(compiler.WrapScript(script, bytes.NewReader(source.Bytes())))
+		file = c.add(name, source.Bytes(), tokens)
+	}
+	for offset, n := range hits {
+		if o, found := file.operations[offset]; found {
+			o.Hits += n
+		}
+	}
+	for offset, n := range entered {
+		if l, found := file.loops[offset]; found {
+			l.Entered += n
+		}
+	}
+	for offset, n := range skipped {
+		if l, found := file.loops[offset]; found {
+			l.Skipped += n
+		}
+	}
+	return err
+}
+
+//adds new file with operations of provided tokens
+func (c *Coverage) add(name string, source []byte, tokens []compiler.Token) *File {
+	file := &File{
+		Name:       name,
+		Source:     append([]byte(nil), source...),
+		operations: make(map[int]*Operation),
+		loops:      make(map[int]*Loop),
+	}
+	for _, t := range tokens {
+		if _, found := file.operations[t.Offset]; found {
+			continue
+		}
+		o := &Operation{Position: t.Position, Command: t.Command}
+		file.Operations = append(file.Operations, o)
+		file.operations[t.Offset] = o
+		if t.Command == "[" {
+			l := &Loop{Start: t.Position}
+			file.Loops = append(file.Loops, l)
+			file.loops[t.Offset] = l
+		}
+	}
+	c.files[name] = file
+	return file
+}
+
+/*
+add coverage collected by other collector. files with the same name are merged, the other files are copied
+*/
+func (c *Coverage) Merge(other *Coverage) {
+	for _, f := range other.Files() {
+		file, found := c.files[f.Name]
+		if !found {
+			tokens := make([]compiler.Token, 0, len(f.Operations))
+			for _, o := range f.Operations {
+				tokens = append(tokens, compiler.Token{Position: o.Position, Command: o.Command})
+			}
+			file = c.add(f.Name, f.Source, tokens)
+		}
+		for _, o := range f.Operations {
+			if target, found := file.operations[o.Offset]; found {
+				target.Hits += o.Hits
+			}
+		}
+		for _, l := range f.Loops {
+			if target, found := file.loops[l.Start.Offset]; found {
+				target.Entered += l.Entered
+				target.Skipped += l.Skipped
+			}
+		}
+	}
+}
+
+//returns covered files ordered by name
+func (c *Coverage) Files() []*File {
+	result := make([]*File, 0, len(c.files))
+	for _, file := range c.files {
+		result = append(result, file)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
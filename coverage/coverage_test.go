@@ -0,0 +1,116 @@
+package coverage
+
+import (
+	"bytes"
+	compiler "github.com/gdtrp/brainfuck"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+const script = ",[\n>++[-]<-\n]>[\n+]"
+
+func collect(t *testing.T, inputs ...string) *Coverage {
+	c, _ := compiler.New()
+	coverage := New(c)
+	for _, input := range inputs {
+		if err := coverage.Compile("test.bf", strings.NewReader(script), strings.NewReader(input), ioutil.Discard); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	return coverage
+}
+
+func TestCoverage_Run(t *testing.T) {
+	f := collect(t, "\x00").Files()[0]
+	operations, covered := f.Covered()
+	if operations != 15 || covered != 4 {
+		t.Fatalf("wrong coverage %v/%v", covered, operations)
+	}
+	if l := f.Loops[0]; l.Entered != 0 || l.Skipped != 1 {
+		t.Fatalf("wrong first loop %v", l)
+	}
+	f = collect(t, "\x00", "\x02").Files()[0]
+	if _, covered := f.Covered(); covered != 13 {
+		t.Fatalf("all operations except the last loop body should be covered but was %v", covered)
+	}
+	if l := f.Loops[0]; l.Entered != 1 || l.Skipped != 1 {
+		t.Fatalf("wrong first loop %v", l)
+	}
+	if l := f.Loops[1]; l.Start.Line != 2 || l.Entered != 2 || l.Skipped != 0 {
+		t.Fatalf("wrong nested loop %v", l)
+	}
+}
+
+func TestCoverage_Merge(t *testing.T) {
+	merged := collect(t, "\x00")
+	merged.Merge(collect(t, "\x02"))
+	expected := collect(t, "\x00", "\x02")
+	var actualLcov, expectedLcov bytes.Buffer
+	merged.WriteLcov(&actualLcov)
+	expected.WriteLcov(&expectedLcov)
+	if actualLcov.String() != expectedLcov.String() {
+		t.Fatalf("merged coverage expected\n%v\nbut was\n%v", expectedLcov.String(), actualLcov.String())
+	}
+}
+
+func TestCoverage_Lcov(t *testing.T) {
+	var buf bytes.Buffer
+	if err := collect(t, "\x01").WriteLcov(&buf); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := "TN:\nSF:test.bf\n" +
+		"BRDA:1,0,0,1\nBRDA:1,0,1,0\n" +
+		"BRDA:2,1,0,1\nBRDA:2,1,1,0\n" +
+		"BRDA:3,2,0,0\nBRDA:3,2,1,1\n" +
+		"BRF:6\nBRH:3\n" +
+		"DA:1,2\nDA:2,3\nDA:3,1\nDA:4,0\n" +
+		"LF:4\nLH:3\nend_of_record\n"
+	if buf.String() != expected {
+		t.Fatalf("wrong lcov expected\n%v\nbut was\n%v", expected, buf.String())
+	}
+}
+
+func TestCoverage_Summary(t *testing.T) {
+	var buf bytes.Buffer
+	if err := collect(t, "\x01").WriteSummary(&buf); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "test.bf                            13/15      86.7%  loops entered 2/3 skipped 1/3\n") {
+		t.Fatalf("wrong summary %v", buf.String())
+	}
+}
+
+func TestCoverage_HTML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := collect(t, "\x00").WriteHTML(&buf); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !strings.Contains(buf.String(), `<span class="partial" title="entered 0, skipped 1">[</span>`) ||
+		!strings.Contains(buf.String(), `<span class="miss" title="0 hits">&gt;</span>`) {
+		t.Fatalf("wrong html %v", buf.String())
+	}
+}
+
+func TestCoverage_Scanner(t *testing.T) {
+	c, _ := compiler.New()
+	strict, _ := compiler.New()
+	strict.SetStrict(true, "#")
+	for _, test := range []struct {
+		name     string
+		compiler compiler.Compiler
+		script   string
+		expected int
+	}{
+		{"ook", c, "#!bf dialect=ook\nOok. Ook. Ook. Ook. Ook! Ook.", 3},
+		{"strict comment", strict, "+. # print it.", 2},
+	} {
+		coverage := New(test.compiler)
+		if err := coverage.Compile("test.bf", strings.NewReader(test.script), nil, ioutil.Discard); err != nil {
+			t.Fatalf("%v: unexpected error %v", test.name, err)
+		}
+		if operations, covered := coverage.Files()[0].Covered(); operations != test.expected || covered != test.expected {
+			t.Fatalf("%v: wrong coverage %v/%v", test.name, covered, operations)
+		}
+	}
+}
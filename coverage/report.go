@@ -0,0 +1,155 @@
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+)
+
+//returns line numbers containing operations and the highest number of hits of operation on every line
+func (f *File) lines() ([]int, map[int]int) {
+	var lines []int
+	hits := make(map[int]int)
+	for _, o := range f.Operations {
+		current, found := hits[o.Line]
+		if !found {
+			lines = append(lines, o.Line)
+		}
+		if !found || o.Hits > current {
+			hits[o.Line] = o.Hits
+		}
+	}
+	sort.Ints(lines)
+	return lines, hits
+}
+
+/*
+write coverage as lcov tracefile. lines are reported with the highest number of hits of their operations,
+every loop is reported as a branch with two outcomes: body entered and loop skipped
+*/
+func (c *Coverage) WriteLcov(writer io.Writer) error {
+	w := bufio.NewWriter(writer)
+	for _, f := range c.Files() {
+		fmt.Fprintf(w, "TN:\nSF:%s\n", f.Name)
+		branchesHit := 0
+		for i, l := range f.Loops {
+			if l.Entered+l.Skipped == 0 {
+				fmt.Fprintf(w, "BRDA:%d,%d,0,-\nBRDA:%d,%d,1,-\n", l.Start.Line, i, l.Start.Line, i)
+				continue
+			}
+			fmt.Fprintf(w, "BRDA:%d,%d,0,%d\nBRDA:%d,%d,1,%d\n", l.Start.Line, i, l.Entered, l.Start.Line, i, l.Skipped)
+			if l.Entered > 0 {
+				branchesHit++
+			}
+			if l.Skipped > 0 {
+				branchesHit++
+			}
+		}
+		fmt.Fprintf(w, "BRF:%d\nBRH:%d\n", 2*len(f.Loops), branchesHit)
+		lines, hits := f.lines()
+		linesHit := 0
+		for _, line := range lines {
+			fmt.Fprintf(w, "DA:%d,%d\n", line, hits[line])
+			if hits[line] > 0 {
+				linesHit++
+			}
+		}
+		fmt.Fprintf(w, "LF:%d\nLH:%d\nend_of_record\n", len(lines), linesHit)
+	}
+	return w.Flush()
+}
+
+func percent(covered int, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return 100 * float64(covered) / float64(total)
+}
+
+/*
+write text summary with covered operations and loops of every file and the total
+*/
+func (c *Coverage) WriteSummary(writer io.Writer) error {
+	w := bufio.NewWriter(writer)
+	var operations, covered, loops, entered, skipped int
+	line := func(name string, operations, covered, loops, entered, skipped int) {
+		fmt.Fprintf(w, "%-30s %6d/%-6d %5.1f%%  loops entered %d/%d skipped %d/%d\n",
+			name, covered, operations, percent(covered, operations), entered, loops, skipped, loops)
+	}
+	for _, f := range c.Files() {
+		o, c := f.Covered()
+		e, s := 0, 0
+		for _, l := range f.Loops {
+			if l.Entered > 0 {
+				e++
+			}
+			if l.Skipped > 0 {
+				s++
+			}
+		}
+		line(f.Name, o, c, len(f.Loops), e, s)
+		operations, covered, loops, entered, skipped = operations+o, covered+c, loops+len(f.Loops), entered+e, skipped+s
+	}
+	line("total", operations, covered, loops, entered, skipped)
+	return w.Flush()
+}
+
+const htmlHeader = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>brainfuck coverage</title>
+<style>
+body { font-family: sans-serif; }
+pre { background: #fafafa; padding: 8px; }
+.hit { background: #c8f0c8; }
+.miss { background: #f5c0c0; }
+.partial { background: #f5e6a0; }
+</style>
+</head>
+<body>
+`
+
+/*
+write html page with summary table and source of every file. executed operations are green, not executed ones
+are red, loops that were only entered or only skipped are yellow. loop tooltips show entered and skipped counts
+*/
+func (c *Coverage) WriteHTML(writer io.Writer) error {
+	w := bufio.NewWriter(writer)
+	w.WriteString(htmlHeader)
+	w.WriteString("<table>\n<tr><th>file</th><th>operations</th><th>coverage</th></tr>\n")
+	files := c.Files()
+	for i, f := range files {
+		operations, covered := f.Covered()
+		fmt.Fprintf(w, "<tr><td><a href=\"#file%d\">%s</a></td><td>%d/%d</td><td>%.1f%%</td></tr>\n",
+			i, html.EscapeString(f.Name), covered, operations, percent(covered, operations))
+	}
+	w.WriteString("</table>\n")
+	for i, f := range files {
+		fmt.Fprintf(w, "<h2 id=\"file%d\">%s</h2>\n<pre>", i, html.EscapeString(f.Name))
+		for offset, b := range f.Source {
+			text := html.EscapeString(string(b))
+			o, found := f.operations[offset]
+			if !found {
+				w.WriteString(text)
+				continue
+			}
+			class, title := "hit", fmt.Sprintf("%d hits", o.Hits)
+			if o.Hits == 0 {
+				class = "miss"
+			}
+			if l, found := f.loops[offset]; found {
+				title = fmt.Sprintf("entered %d, skipped %d", l.Entered, l.Skipped)
+				if o.Hits > 0 && (l.Entered == 0 || l.Skipped == 0) {
+					class = "partial"
+				}
+			}
+			fmt.Fprintf(w, "<span class=\"%s\" title=\"%s\">%s</span>", class, title, text)
+		}
+		w.WriteString("</pre>\n")
+	}
+	w.WriteString("</body>\n</html>\n")
+	return w.Flush()
+}
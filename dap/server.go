@@ -0,0 +1,562 @@
+/*
+Package dap implements debug adapter protocol for brainfuck scripts.
+
+Server runs the script with debug.Debugger in a single thread. Loop nesting is shown as call stack, where the top
+frame is the operation executed next and every enclosing loop is a frame positioned at its opening bracket.
+Tape cells around the pointer and pointer registers are available as variables.
+*/
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	compiler "github.com/gdtrp/brainfuck"
+	"github.com/gdtrp/brainfuck/debug"
+	"github.com/gdtrp/brainfuck/internal/wire"
+	"github.com/gdtrp/brainfuck/stack"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+//the only thread of the script
+const threadID = 1
+
+//variables references
+const (
+	registersReference = 1
+	tapeReference      = 2
+)
+
+//number of cells shown on each side of current cell
+const tapeRadius = 8
+
+//number of recorded operations available for stepping back
+const historySteps = 100000
+
+//Server is a debug adapter communicating over a single stream
+type Server struct {
+	compiler compiler.Compiler
+	debugger *debug.Debugger
+	writer   io.Writer
+	//protects writer and message sequence
+	writeMutex sync.Mutex
+	seq        int
+	//protects execution state
+	mutex       sync.Mutex
+	launch      *launchArguments
+	configured  bool
+	started     bool
+	paused      bool
+	stopOnEntry bool
+	resume      chan func(d *debug.Debugger)
+	finished    chan struct{}
+	//ids of debugger breakpoints set by client
+	breakpoints []int
+	//protocol breakpoint ids by debugger breakpoint ids
+	breakpointIDs map[int]int
+	lastID        int
+}
+
+type handler func(s *Server, request *message) error
+
+var handlers = map[string]handler{
+	"initialize":        (*Server).initialize,
+	"launch":            (*Server).launchRequest,
+	"setBreakpoints":    (*Server).setBreakpoints,
+	"configurationDone": (*Server).configurationDone,
+	"threads":           (*Server).threads,
+	"stackTrace":        (*Server).stackTrace,
+	"scopes":            (*Server).scopes,
+	"variables":         (*Server).variables,
+	"continue":          (*Server).continueRequest,
+	"next":              (*Server).next,
+	"stepIn":            (*Server).stepIn,
+	"stepOut":           (*Server).stepOut,
+	"stepBack":          (*Server).stepBack,
+	"reverseContinue":   (*Server).reverseContinue,
+	"pause":             (*Server).pause,
+	"terminate":         (*Server).terminate,
+}
+
+/*
+create new server. provided custom operations are available to debugged scripts, same as in compiler.New
+*/
+func NewServer(ops ...stack.ExternalOperation) (*Server, error) {
+	c, err := compiler.New(ops...)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		compiler:      c,
+		resume:        make(chan func(d *debug.Debugger)),
+		finished:      make(chan struct{}),
+		breakpointIDs: make(map[int]int),
+	}
+	s.debugger = debug.New(c, s.stopped)
+	s.debugger.EnableHistory(historySteps)
+	return s, nil
+}
+
+/*
+serve requests read from reader and write responses and events to writer until disconnect request is received
+or reader is finished. running script is aborted on exit
+*/
+func (s *Server) Serve(reader io.Reader, writer io.Writer) error {
+	s.writer = writer
+	buffered := bufio.NewReader(reader)
+	defer s.abort()
+	for {
+		body, err := wire.ReadMessage(buffered)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var request message
+		if err := json.Unmarshal(body, &request); err != nil {
+			return err
+		}
+		if request.Type != "request" {
+			continue
+		}
+		if request.Command == "disconnect" {
+			s.abort()
+			return s.respond(&request, nil)
+		}
+		h, found := handlers[request.Command]
+		if !found {
+			err = s.fail(&request, errors.New("unsupported command "+request.Command))
+		} else {
+			err = h(s, &request)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) send(msg message) error {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+	s.seq++
+	msg.Seq = s.seq
+	return wire.WriteMessage(s.writer, msg)
+}
+
+func (s *Server) respond(request *message, body interface{}) error {
+	success := true
+	response := message{Type: "response", Command: request.Command, RequestSeq: request.Seq, Success: &success}
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		response.Body = raw
+	}
+	return s.send(response)
+}
+
+func (s *Server) fail(request *message, err error) error {
+	success := false
+	return s.send(message{Type: "response", Command: request.Command, RequestSeq: request.Seq, Success: &success,
+		Message: err.Error()})
+}
+
+func (s *Server) event(event string, body interface{}) error {
+	msg := message{Type: "event", Event: event}
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		msg.Body = raw
+	}
+	return s.send(msg)
+}
+
+func (s *Server) initialize(request *message) error {
+	if err := s.respond(request, capabilities{
+		SupportsConfigurationDoneRequest: true,
+		SupportsConditionalBreakpoints:   true,
+		SupportsTerminateRequest:         true,
+		SupportsStepBack:                 true,
+	}); err != nil {
+		return err
+	}
+	return s.event("initialized", nil)
+}
+
+func (s *Server) launchRequest(request *message) error {
+	var args launchArguments
+	if err := json.Unmarshal(request.Arguments, &args); err != nil {
+		return s.fail(request, err)
+	}
+	if args.Program == "" {
+		return s.fail(request, errors.New("program is not provided"))
+	}
+	s.mutex.Lock()
+	s.launch = &args
+	ready := s.configured
+	s.mutex.Unlock()
+	if err := s.respond(request, nil); err != nil {
+		return err
+	}
+	if ready {
+		return s.start()
+	}
+	return nil
+}
+
+func (s *Server) configurationDone(request *message) error {
+	s.mutex.Lock()
+	s.configured = true
+	ready := s.launch != nil
+	s.mutex.Unlock()
+	if err := s.respond(request, nil); err != nil {
+		return err
+	}
+	if ready {
+		return s.start()
+	}
+	return nil
+}
+
+//start script execution in a separate goroutine
+func (s *Server) start() error {
+	s.mutex.Lock()
+	args := s.launch
+	if s.started {
+		s.mutex.Unlock()
+		return nil
+	}
+	s.started = true
+	s.stopOnEntry = args.StopOnEntry
+	s.mutex.Unlock()
+	script, err := os.Open(args.Program)
+	if err != nil {
+		return s.finish(err)
+	}
+	var input io.Reader = strings.NewReader(args.Input)
+	var inputFile *os.File
+	if args.InputFile != "" {
+		if inputFile, err = os.Open(args.InputFile); err != nil {
+			script.Close()
+			return s.finish(err)
+		}
+		input = inputFile
+	}
+	if !args.StopOnEntry {
+		s.debugger.Continue()
+	}
+	go func() {
+		err := s.debugger.Run(script, input, outputWriter{s})
+		script.Close()
+		if inputFile != nil {
+			inputFile.Close()
+		}
+		s.finish(err)
+	}()
+	return nil
+}
+
+//report script result to the client
+func (s *Server) finish(err error) error {
+	defer close(s.finished)
+	code := 0
+	if err != nil && err != debug.ErrAborted {
+		code = 1
+		if err := s.event("output", outputBody{Category: "stderr", Output: err.Error() + "\n"}); err != nil {
+			return err
+		}
+	}
+	if err := s.event("exited", exitedBody{ExitCode: code}); err != nil {
+		return err
+	}
+	return s.event("terminated", nil)
+}
+
+//controller of the debugger. called from script goroutine and waits until client resumes execution
+func (s *Server) stopped(d *debug.Debugger, reason debug.Reason) error {
+	s.mutex.Lock()
+	entry := s.stopOnEntry
+	s.stopOnEntry = false
+	s.paused = true
+	s.mutex.Unlock()
+	body := stoppedBody{Reason: string(reason), ThreadID: threadID, AllThreadsStopped: true}
+	switch reason {
+	case debug.ReasonCursor:
+		body.Reason = "goto"
+	case debug.ReasonStep:
+		if entry {
+			body.Reason = "entry"
+		}
+	case debug.ReasonBreakpoint:
+		if id, found := s.breakpointIDs[d.HitBreakpoint()]; found {
+			body.HitBreakpointIds = []int{id}
+		}
+	case debug.ReasonWrite:
+		body.Reason = "data breakpoint"
+	case debug.ReasonHistory:
+		body.Reason = "step"
+		body.Description = "the oldest recorded step"
+	}
+	if err := s.event("stopped", body); err != nil {
+		d.Abort()
+		return err
+	}
+	resume := <-s.resume
+	resume(d)
+	return nil
+}
+
+//run function with the debugger. function is called immediately if script is not running
+//or scheduled before the next operation otherwise
+func (s *Server) withDebugger(f func(d *debug.Debugger)) {
+	s.mutex.Lock()
+	running := s.started && !s.paused
+	s.mutex.Unlock()
+	if running {
+		s.debugger.Do(f)
+	} else {
+		f(s.debugger)
+	}
+}
+
+//resume paused script. response is sent before script continues
+func (s *Server) resumeWith(request *message, body interface{}, f func(d *debug.Debugger)) error {
+	s.mutex.Lock()
+	paused := s.paused
+	s.paused = false
+	s.mutex.Unlock()
+	if !paused {
+		return s.fail(request, errors.New("script is not paused"))
+	}
+	if err := s.respond(request, body); err != nil {
+		return err
+	}
+	s.resume <- f
+	return nil
+}
+
+func (s *Server) abort() {
+	s.mutex.Lock()
+	started, paused := s.started, s.paused
+	s.mutex.Unlock()
+	if !started {
+		return
+	}
+	if !paused {
+		s.debugger.Do((*debug.Debugger).Abort)
+	}
+	//script can be paused right before scheduled abort is executed
+	select {
+	case s.resume <- (*debug.Debugger).Abort:
+		<-s.finished
+	case <-s.finished:
+	}
+}
+
+func (s *Server) setBreakpoints(request *message) error {
+	var args setBreakpointsArguments
+	if err := json.Unmarshal(request.Arguments, &args); err != nil {
+		return s.fail(request, err)
+	}
+	result := make([]breakpoint, 0, len(args.Breakpoints))
+	var created []debug.Breakpoint
+	var ids []int
+	for _, b := range args.Breakpoints {
+		created = append(created, nil)
+		ids = append(ids, 0)
+		position := debug.PositionBreakpoint{Line: b.Line, Column: b.Column}
+		var bp debug.Breakpoint = position
+		if b.Condition != "" {
+			condition, err := debug.ParseBreakpoint(b.Condition)
+			c, ok := condition.(*debug.ConditionBreakpoint)
+			if err != nil || !ok {
+				result = append(result, breakpoint{Verified: false, Line: b.Line, Column: b.Column,
+					Message: fmt.Sprintf("unsupported condition %q", b.Condition)})
+				continue
+			}
+			bp = conditional{position: position, condition: c}
+		}
+		s.lastID++
+		created[len(created)-1] = bp
+		ids[len(ids)-1] = s.lastID
+		result = append(result, breakpoint{ID: s.lastID, Verified: true, Line: b.Line, Column: b.Column})
+	}
+	s.withDebugger(func(d *debug.Debugger) {
+		for _, id := range s.breakpoints {
+			d.RemoveBreakpoint(id)
+			delete(s.breakpointIDs, id)
+		}
+		s.breakpoints = nil
+		for i, bp := range created {
+			if bp == nil {
+				continue
+			}
+			id := d.AddBreakpoint(bp)
+			s.breakpoints = append(s.breakpoints, id)
+			s.breakpointIDs[id] = ids[i]
+		}
+	})
+	return s.respond(request, breakpointsBody{Breakpoints: result})
+}
+
+func (s *Server) threads(request *message) error {
+	return s.respond(request, threadsBody{Threads: []thread{{ID: threadID, Name: "main"}}})
+}
+
+//returns true if debugger state can be inspected
+func (s *Server) isPaused() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.paused
+}
+
+func (s *Server) stackTrace(request *message) error {
+	if !s.isPaused() {
+		return s.fail(request, errors.New("script is not paused"))
+	}
+	src := &source{Name: s.launch.Program, Path: s.launch.Program}
+	element := s.debugger.Current()
+	position := element.Position()
+	frames := []stackFrame{{
+		ID:     0,
+		Name:   fmt.Sprintf("operation '%s'", element.Operation().Command()),
+		Source: src,
+		Line:   position.Line,
+		Column: position.Column,
+	}}
+	for loop := element.CurrentLoop(); loop != nil; loop = loop.GetPreviousLoop() {
+		start := loop.CurrentOperation().Position()
+		frames = append(frames, stackFrame{
+			ID:     len(frames),
+			Name:   fmt.Sprintf("loop at %s", start),
+			Source: src,
+			Line:   start.Line,
+			Column: start.Column,
+		})
+	}
+	return s.respond(request, stackTraceBody{StackFrames: frames, TotalFrames: len(frames)})
+}
+
+func (s *Server) scopes(request *message) error {
+	return s.respond(request, scopesBody{Scopes: []scope{
+		{Name: "Registers", VariablesReference: registersReference},
+		{Name: "Tape", VariablesReference: tapeReference},
+	}})
+}
+
+func (s *Server) variables(request *message) error {
+	var args variablesArguments
+	if err := json.Unmarshal(request.Arguments, &args); err != nil {
+		return s.fail(request, err)
+	}
+	if !s.isPaused() {
+		return s.fail(request, errors.New("script is not paused"))
+	}
+	ctx := s.debugger.Context()
+	var result []variable
+	switch args.VariablesReference {
+	case registersReference:
+		result = []variable{
+			{Name: "pointer", Value: fmt.Sprint(ctx.CurrentIdx)},
+			{Name: "cell", Value: cellValue(ctx.GetCurrentCell())},
+			{Name: "depth", Value: fmt.Sprint(s.debugger.Depth())},
+		}
+	case tapeReference:
+		from, to := ctx.CurrentIdx-tapeRadius, ctx.CurrentIdx+tapeRadius
+		if from < 0 {
+			from = 0
+		}
+		if to >= ctx.Cells() {
+			to = ctx.Cells() - 1
+		}
+		for i := from; i <= to; i++ {
+			name := fmt.Sprintf("[%d]", i)
+			if i == ctx.CurrentIdx {
+				name += " *"
+			}
+			value, _ := ctx.GetCell(i)
+			result = append(result, variable{Name: name, Value: cellValue(value)})
+		}
+	default:
+		return s.fail(request, fmt.Errorf("unknown variables reference %d", args.VariablesReference))
+	}
+	return s.respond(request, variablesBody{Variables: result})
+}
+
+//format cell value as number and printable character
+func cellValue(value uint32) string {
+	if value >= 0x20 && value < 0x7f {
+		return fmt.Sprintf("%d '%c'", value, rune(value))
+	}
+	return fmt.Sprint(value)
+}
+
+func (s *Server) continueRequest(request *message) error {
+	return s.resumeWith(request, continuedBody{AllThreadsContinued: true}, (*debug.Debugger).Continue)
+}
+
+func (s *Server) next(request *message) error {
+	return s.resumeWith(request, nil, (*debug.Debugger).StepOver)
+}
+
+func (s *Server) stepIn(request *message) error {
+	return s.resumeWith(request, nil, (*debug.Debugger).Step)
+}
+
+func (s *Server) stepOut(request *message) error {
+	return s.resumeWith(request, nil, (*debug.Debugger).StepOut)
+}
+
+func (s *Server) stepBack(request *message) error {
+	return s.resumeWith(request, nil, (*debug.Debugger).StepBack)
+}
+
+func (s *Server) reverseContinue(request *message) error {
+	return s.resumeWith(request, nil, (*debug.Debugger).ReverseContinue)
+}
+
+func (s *Server) pause(request *message) error {
+	s.debugger.Pause()
+	return s.respond(request, nil)
+}
+
+func (s *Server) terminate(request *message) error {
+	if err := s.respond(request, nil); err != nil {
+		return err
+	}
+	s.abort()
+	return nil
+}
+
+//outputWriter sends script output as output events
+type outputWriter struct {
+	server *Server
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	if err := w.server.event("output", outputBody{Category: "stdout", Output: string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+//conditional breakpoint pauses at position only if condition is true
+type conditional struct {
+	position  debug.PositionBreakpoint
+	condition *debug.ConditionBreakpoint
+}
+
+func (b conditional) Hit(d *debug.Debugger, element stack.OperationalElement) bool {
+	return b.position.Hit(d, element) && b.condition.Holds(d.Context())
+}
+
+func (b conditional) String() string {
+	return b.position.String() + " if " + b.condition.String()
+}
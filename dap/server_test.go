@@ -0,0 +1,265 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/gdtrp/brainfuck/internal/wire"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+//client drives the server through its stdin and stdout
+type client struct {
+	t      *testing.T
+	writer *io.PipeWriter
+	reader *bufio.Reader
+	done   chan error
+	seq    int
+	//events received while waiting for responses
+	events []message
+}
+
+func newClient(t *testing.T) *client {
+	server, err := NewServer()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	c := &client{t: t, writer: inWriter, reader: bufio.NewReader(outReader), done: make(chan error, 1)}
+	go func() {
+		err := server.Serve(inReader, outWriter)
+		outWriter.Close()
+		c.done <- err
+	}()
+	return c
+}
+
+func (c *client) read() message {
+	body, err := wire.ReadMessage(c.reader)
+	if err != nil {
+		c.t.Fatalf("unexpected error %v", err)
+	}
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		c.t.Fatalf("unexpected error %v", err)
+	}
+	return msg
+}
+
+//send request and wait for its response. body of successful response is decoded into value
+func (c *client) request(command string, arguments interface{}, value interface{}) message {
+	c.seq++
+	raw, _ := json.Marshal(arguments)
+	if err := wire.WriteMessage(c.writer, message{Seq: c.seq, Type: "request", Command: command, Arguments: raw}); err != nil {
+		c.t.Fatalf("unexpected error %v", err)
+	}
+	for {
+		msg := c.read()
+		if msg.Type == "event" {
+			c.events = append(c.events, msg)
+			continue
+		}
+		if msg.RequestSeq != c.seq || msg.Command != command {
+			c.t.Fatalf("wrong response %v", msg)
+		}
+		if value != nil && msg.Success != nil && *msg.Success {
+			if err := json.Unmarshal(msg.Body, value); err != nil {
+				c.t.Fatalf("unexpected error %v", err)
+			}
+		}
+		return msg
+	}
+}
+
+//wait for event and decode its body into value
+func (c *client) waitEvent(event string, value interface{}) {
+	for {
+		var msg message
+		if len(c.events) > 0 {
+			msg, c.events = c.events[0], c.events[1:]
+		} else {
+			msg = c.read()
+		}
+		if msg.Type == "event" && msg.Event == event {
+			if value != nil {
+				if err := json.Unmarshal(msg.Body, value); err != nil {
+					c.t.Fatalf("unexpected error %v", err)
+				}
+			}
+			return
+		}
+	}
+}
+
+func (c *client) disconnect() {
+	c.request("disconnect", nil, nil)
+	if err := <-c.done; err != nil {
+		c.t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func writeScript(t *testing.T, script string) string {
+	dir, err := ioutil.TempDir("", "dap")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "test.bf")
+	if err := ioutil.WriteFile(path, []byte(script), 0644); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	return path
+}
+
+func launch(c *client, program string, stopOnEntry bool, breakpoints ...sourceBreakpoint) {
+	var caps capabilities
+	c.request("initialize", map[string]string{"adapterID": "bf"}, &caps)
+	if !caps.SupportsConfigurationDoneRequest {
+		c.t.Fatalf("configuration done should be supported")
+	}
+	c.waitEvent("initialized", nil)
+	if msg := c.request("launch", launchArguments{Program: program, StopOnEntry: stopOnEntry, Input: "A"}, nil); !*msg.Success {
+		c.t.Fatalf("launch failed %v", msg.Message)
+	}
+	var result breakpointsBody
+	c.request("setBreakpoints", setBreakpointsArguments{Source: source{Path: program}, Breakpoints: breakpoints}, &result)
+	for _, b := range result.Breakpoints {
+		if !b.Verified {
+			c.t.Fatalf("breakpoint is not verified %v", b)
+		}
+	}
+	c.request("configurationDone", nil, nil)
+}
+
+func TestServer_StopOnEntryAndStep(t *testing.T) {
+	c := newClient(t)
+	launch(c, writeScript(t, "+\n[>+<-]>."), true)
+	var stopped stoppedBody
+	c.waitEvent("stopped", &stopped)
+	if stopped.Reason != "entry" {
+		t.Fatalf("entry reason expected but was %v", stopped.Reason)
+	}
+	c.request("stepIn", map[string]int{"threadId": threadID}, nil)
+	c.waitEvent("stopped", &stopped)
+	c.request("stepIn", map[string]int{"threadId": threadID}, nil)
+	c.waitEvent("stopped", &stopped)
+	var trace stackTraceBody
+	c.request("stackTrace", map[string]int{"threadId": threadID}, &trace)
+	if len(trace.StackFrames) != 2 || trace.StackFrames[0].Line != 2 || trace.StackFrames[0].Column != 2 ||
+		trace.StackFrames[1].Name != "loop at 2:1" {
+		t.Fatalf("wrong stack trace %v", trace.StackFrames)
+	}
+	var variables variablesBody
+	c.request("variables", variablesArguments{VariablesReference: registersReference}, &variables)
+	if variables.Variables[0].Value != "0" || variables.Variables[1].Value != "1" || variables.Variables[2].Value != "1" {
+		t.Fatalf("wrong registers %v", variables.Variables)
+	}
+	c.request("stepOut", map[string]int{"threadId": threadID}, nil)
+	c.waitEvent("stopped", &stopped)
+	c.request("stackTrace", map[string]int{"threadId": threadID}, &trace)
+	if len(trace.StackFrames) != 1 || trace.StackFrames[0].Column != 7 {
+		t.Fatalf("wrong stack trace %v", trace.StackFrames)
+	}
+	c.request("variables", variablesArguments{VariablesReference: tapeReference}, &variables)
+	if variables.Variables[1].Name != "[1]" || variables.Variables[1].Value != "1" {
+		t.Fatalf("wrong tape %v", variables.Variables)
+	}
+	c.request("continue", map[string]int{"threadId": threadID}, nil)
+	var output outputBody
+	c.waitEvent("output", &output)
+	if output.Output != "\x01" {
+		t.Fatalf("wrong output %q", output.Output)
+	}
+	var exited exitedBody
+	c.waitEvent("exited", &exited)
+	if exited.ExitCode != 0 {
+		t.Fatalf("wrong exit code %v", exited.ExitCode)
+	}
+	c.waitEvent("terminated", nil)
+	c.disconnect()
+}
+
+func TestServer_Breakpoints(t *testing.T) {
+	c := newClient(t)
+	launch(c, writeScript(t, ",[\n-.\n]"), false,
+		sourceBreakpoint{Line: 2, Column: 2, Condition: "cell == 62"})
+	var stopped stoppedBody
+	c.waitEvent("stopped", &stopped)
+	if stopped.Reason != "breakpoint" || len(stopped.HitBreakpointIds) != 1 {
+		t.Fatalf("wrong stop %v", stopped)
+	}
+	var variables variablesBody
+	c.request("variables", variablesArguments{VariablesReference: registersReference}, &variables)
+	if variables.Variables[1].Value != "62 '>'" {
+		t.Fatalf("wrong cell value %v", variables.Variables[1])
+	}
+	c.request("setBreakpoints", setBreakpointsArguments{Breakpoints: nil}, nil)
+	c.request("continue", map[string]int{"threadId": threadID}, nil)
+	c.waitEvent("terminated", nil)
+	c.disconnect()
+}
+
+func TestServer_DisconnectWhilePaused(t *testing.T) {
+	c := newClient(t)
+	launch(c, writeScript(t, "+[]"), true)
+	c.waitEvent("stopped", nil)
+	if msg := c.request("continue", map[string]int{"threadId": threadID}, nil); !*msg.Success {
+		t.Fatalf("continue failed %v", msg.Message)
+	}
+	c.request("pause", map[string]int{"threadId": threadID}, nil)
+	var stopped stoppedBody
+	c.waitEvent("stopped", &stopped)
+	if stopped.Reason != "pause" {
+		t.Fatalf("pause reason expected but was %v", stopped.Reason)
+	}
+	c.disconnect()
+}
+
+func TestServer_NotPaused(t *testing.T) {
+	c := newClient(t)
+	if msg := c.request("stackTrace", map[string]int{"threadId": threadID}, nil); *msg.Success {
+		t.Fatalf("stack trace should fail")
+	}
+	if msg := c.request("unknown", nil, nil); *msg.Success {
+		t.Fatalf("unknown command should fail")
+	}
+	c.disconnect()
+}
+
+func TestServer_StepBack(t *testing.T) {
+	c := newClient(t)
+	launch(c, writeScript(t, "++>+"), false, sourceBreakpoint{Line: 1, Column: 4})
+	var stopped stoppedBody
+	c.waitEvent("stopped", &stopped)
+	if msg := c.request("stepBack", map[string]int{"threadId": threadID}, nil); !*msg.Success {
+		t.Fatalf("step back failed %v", msg.Message)
+	}
+	c.waitEvent("stopped", &stopped)
+	var trace stackTraceBody
+	c.request("stackTrace", map[string]int{"threadId": threadID}, &trace)
+	if trace.StackFrames[0].Column != 3 {
+		t.Fatalf("wrong stack trace %v", trace.StackFrames)
+	}
+	var variables variablesBody
+	c.request("variables", variablesArguments{VariablesReference: registersReference}, &variables)
+	if variables.Variables[0].Value != "0" || variables.Variables[1].Value != "2" {
+		t.Fatalf("wrong registers %v", variables.Variables)
+	}
+	c.request("reverseContinue", map[string]int{"threadId": threadID}, nil)
+	c.waitEvent("stopped", &stopped)
+	if stopped.Reason != "step" || stopped.Description != "the oldest recorded step" {
+		t.Fatalf("wrong stop %v", stopped)
+	}
+	c.request("continue", map[string]int{"threadId": threadID}, nil)
+	c.waitEvent("stopped", &stopped)
+	if stopped.Reason != "breakpoint" {
+		t.Fatalf("breakpoint should be hit again %v", stopped)
+	}
+	c.request("continue", map[string]int{"threadId": threadID}, nil)
+	c.waitEvent("terminated", nil)
+	c.disconnect()
+}
@@ -0,0 +1,148 @@
+package debug
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gdtrp/brainfuck/stack"
+	"strconv"
+	"strings"
+)
+
+//Breakpoint decides whether execution must be paused
+type Breakpoint interface {
+	//returns true if execution must be paused before the operation
+	Hit(d *Debugger, element stack.OperationalElement) bool
+	String() string
+}
+
+//PositionBreakpoint pauses execution before operation at script position.
+//if column is 0 execution is paused every time the line is entered
+type PositionBreakpoint struct {
+	Line   int
+	Column int
+}
+
+func (b PositionBreakpoint) Hit(d *Debugger, element stack.OperationalElement) bool {
+	position := element.Position()
+	if position.Line != b.Line {
+		return false
+	}
+	if b.Column != 0 {
+		return position.Column == b.Column
+	}
+	//line is entered from another line or loop jumped back to the start of the line
+	return d.previous.Line != b.Line || d.previous.Offset >= position.Offset
+}
+
+func (b PositionBreakpoint) position() stack.Position {
+	return stack.Position{Line: b.Line, Column: b.Column}
+}
+
+func (b PositionBreakpoint) String() string {
+	if b.Column == 0 {
+		return fmt.Sprintf("line %d", b.Line)
+	}
+	return fmt.Sprintf("position %d:%d", b.Line, b.Column)
+}
+
+//ConditionBreakpoint pauses execution when condition becomes true
+type ConditionBreakpoint struct {
+	//"cell" or "ptr"
+	Subject string
+	//index of compared cell. negative value means current cell
+	Cell     int
+	Operator string
+	Value    int
+	//condition result on previous check
+	active bool
+}
+
+var operators = map[string]func(int, int) bool{
+	"==": func(a, b int) bool { return a == b },
+	"!=": func(a, b int) bool { return a != b },
+	"<":  func(a, b int) bool { return a < b },
+	"<=": func(a, b int) bool { return a <= b },
+	">":  func(a, b int) bool { return a > b },
+	">=": func(a, b int) bool { return a >= b },
+}
+
+func (b *ConditionBreakpoint) Hit(d *Debugger, _ stack.OperationalElement) bool {
+	result := b.Holds(d.Context())
+	hit := result && !b.active
+	b.active = result
+	return hit
+}
+
+//returns true if condition is true for provided context
+func (b *ConditionBreakpoint) Holds(ctx *stack.Context) bool {
+	var value int
+	if b.Subject == "ptr" {
+		value = ctx.CurrentIdx
+	} else if b.Cell < 0 {
+		value = int(ctx.GetCurrentCell())
+	} else if v, err := ctx.GetCell(b.Cell); err == nil {
+		value = int(v)
+	} else {
+		return false
+	}
+	return operators[b.Operator](value, b.Value)
+}
+
+func (b *ConditionBreakpoint) String() string {
+	if b.Subject == "cell" && b.Cell >= 0 {
+		return fmt.Sprintf("cell %d %s %d", b.Cell, b.Operator, b.Value)
+	}
+	return fmt.Sprintf("%s %s %d", b.Subject, b.Operator, b.Value)
+}
+
+/*
+parse breakpoint definition. supported formats:
+"12" line breakpoint, "12:5" position breakpoint,
+"cell 3 == 10" cell value condition, "cell > 0" current cell condition, "ptr == 4" pointer condition
+*/
+func ParseBreakpoint(text string) (Breakpoint, error) {
+	fields := strings.Fields(text)
+	if len(fields) == 1 {
+		return parsePosition(fields[0])
+	}
+	if len(fields) < 3 || len(fields) > 4 || fields[0] != "cell" && fields[0] != "ptr" {
+		return nil, fmt.Errorf("unsupported breakpoint %q", text)
+	}
+	b := &ConditionBreakpoint{Subject: fields[0], Cell: -1}
+	if len(fields) == 4 {
+		if b.Subject != "cell" {
+			return nil, fmt.Errorf("unsupported breakpoint %q", text)
+		}
+		cell, err := strconv.Atoi(fields[1])
+		if err != nil || cell < 0 {
+			return nil, fmt.Errorf("wrong cell index %q", fields[1])
+		}
+		b.Cell = cell
+		fields = fields[1:]
+	}
+	if _, found := operators[fields[1]]; !found {
+		return nil, fmt.Errorf("unsupported operator %q", fields[1])
+	}
+	b.Operator = fields[1]
+	value, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("wrong value %q", fields[2])
+	}
+	b.Value = value
+	return b, nil
+}
+
+func parsePosition(text string) (PositionBreakpoint, error) {
+	parts := strings.SplitN(text, ":", 2)
+	line, err := strconv.Atoi(parts[0])
+	if err != nil || line < 1 {
+		return PositionBreakpoint{}, errors.New("wrong line number " + parts[0])
+	}
+	b := PositionBreakpoint{Line: line}
+	if len(parts) == 2 {
+		if b.Column, err = strconv.Atoi(parts[1]); err != nil || b.Column < 1 {
+			return PositionBreakpoint{}, errors.New("wrong column number " + parts[1])
+		}
+	}
+	return b, nil
+}
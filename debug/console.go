@@ -0,0 +1,148 @@
+package debug
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//number of cells printed on each side of current cell
+const tapeRadius = 4
+
+const consoleHelp = `commands:
+  s, step              execute single operation
+  n, next              step over the loop
+  c, continue          run until breakpoint
+  u, until LINE:COL    run to cursor position
+  bs, back             step back in history
+  rc, reverse          travel back to the previous breakpoint
+  w, write CELL        travel back to the previous write of the cell
+  b, break SPEC        add breakpoint: LINE, LINE:COL, "cell N == V", "cell > V", "ptr == N"
+  d, delete ID         remove breakpoint
+  l, list              list breakpoints
+  p, print [RADIUS]    print tape around current cell
+  q, quit              abort execution
+`
+
+/*
+create controller which reads commands line by line from input and writes debugger messages to output.
+execution is aborted when input is finished
+*/
+func Console(input io.Reader, output io.Writer) Controller {
+	scanner := bufio.NewScanner(input)
+	return func(d *Debugger, reason Reason) error {
+		element := d.Current()
+		position := element.Position()
+		fmt.Fprintf(output, "paused (%s", reason)
+		if id := d.HitBreakpoint(); id != 0 {
+			fmt.Fprintf(output, " %d", id)
+		}
+		fmt.Fprintf(output, ") at %s before '%s', loop depth %d",
+			position, element.Operation().Command(), d.Depth())
+		if shown, live := d.StepNumber(); shown != live {
+			fmt.Fprintf(output, ", history step %d of %d", shown, live)
+		}
+		fmt.Fprintln(output)
+		d.PrintTape(output, tapeRadius)
+		for {
+			fmt.Fprint(output, "(bf) ")
+			if !scanner.Scan() {
+				d.Abort()
+				return scanner.Err()
+			}
+			fields := strings.Fields(scanner.Text())
+			if len(fields) == 0 {
+				continue
+			}
+			argument := strings.Join(fields[1:], " ")
+			switch fields[0] {
+			case "s", "step":
+				d.Step()
+				return nil
+			case "n", "next":
+				d.StepOver()
+				return nil
+			case "c", "continue":
+				d.Continue()
+				return nil
+			case "u", "until":
+				target, err := parsePosition(argument)
+				if err != nil || target.Column == 0 {
+					fmt.Fprintln(output, "position LINE:COL expected")
+					continue
+				}
+				d.RunTo(target.position())
+				return nil
+			case "bs", "back":
+				if d.history == nil {
+					fmt.Fprintln(output, "history is disabled")
+					continue
+				}
+				d.StepBack()
+				return nil
+			case "rc", "reverse":
+				if d.history == nil {
+					fmt.Fprintln(output, "history is disabled")
+					continue
+				}
+				d.ReverseContinue()
+				return nil
+			case "w", "write":
+				if d.history == nil {
+					fmt.Fprintln(output, "history is disabled")
+					continue
+				}
+				cell, err := strconv.Atoi(argument)
+				if err != nil || cell < 0 {
+					fmt.Fprintln(output, "cell index expected")
+					continue
+				}
+				d.ReverseToWrite(cell)
+				return nil
+			case "b", "break":
+				b, err := ParseBreakpoint(argument)
+				if err != nil {
+					fmt.Fprintln(output, err)
+					continue
+				}
+				fmt.Fprintf(output, "breakpoint %d: %s\n", d.AddBreakpoint(b), b)
+			case "d", "delete":
+				id, err := strconv.Atoi(argument)
+				if _, found := d.Breakpoints()[id]; err != nil || !found {
+					fmt.Fprintf(output, "unknown breakpoint %q\n", argument)
+					continue
+				}
+				d.RemoveBreakpoint(id)
+			case "l", "list":
+				printBreakpoints(d, output)
+			case "p", "print":
+				radius := tapeRadius
+				if argument != "" {
+					if r, err := strconv.Atoi(argument); err == nil && r >= 0 {
+						radius = r
+					}
+				}
+				d.PrintTape(output, radius)
+			case "q", "quit":
+				d.Abort()
+				return nil
+			default:
+				fmt.Fprint(output, consoleHelp)
+			}
+		}
+	}
+}
+
+func printBreakpoints(d *Debugger, output io.Writer) {
+	ids := make([]int, 0, len(d.Breakpoints()))
+	for id := range d.Breakpoints() {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		fmt.Fprintf(output, "breakpoint %d: %s\n", id, d.Breakpoints()[id])
+	}
+}
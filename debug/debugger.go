@@ -0,0 +1,508 @@
+/*
+Package debug implements step debugger for brainfuck scripts.
+
+Debugger pauses execution before operations popped from the stack and passes control to the Controller,
+which inspects the state and decides how to resume: step single operation, step over or out of the loop,
+continue until the next breakpoint or run to the cursor position.
+
+When history is enabled debugger also records undo information of executed operations and can travel back:
+step backwards, reverse continue to the previous breakpoint or to the previous write of a cell. While debugger
+travels in history the context shows the restored state, the live state is restored before execution resumes.
+*/
+package debug
+
+import (
+	"errors"
+	"fmt"
+	compiler "github.com/gdtrp/brainfuck"
+	"github.com/gdtrp/brainfuck/stack"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+//ErrAborted is returned by Run if execution was stopped by controller
+var ErrAborted = errors.New("execution is aborted by debugger")
+
+//Reason why execution is paused
+type Reason string
+
+const (
+	//single step is finished
+	ReasonStep Reason = "step"
+	//breakpoint is hit
+	ReasonBreakpoint Reason = "breakpoint"
+	//cursor position is reached
+	ReasonCursor Reason = "cursor"
+	//pause is requested
+	ReasonPause Reason = "pause"
+	//watched cell is written by the operation
+	ReasonWrite Reason = "write"
+	//the oldest recorded step is reached while traveling back
+	ReasonHistory Reason = "history"
+)
+
+//Controller is called every time execution is paused. controller must call one of resume methods
+//(Step, StepOver, StepOut, Continue, RunTo, StepBack, ReverseContinue, ReverseToWrite) before returning.
+//returned error aborts execution
+type Controller func(d *Debugger, reason Reason) error
+
+//execution mode defines when the next pause happens
+type mode int
+
+const (
+	modeStep mode = iota
+	modeOver
+	modeContinue
+	modeCursor
+	modePause
+	modeBack
+	modeReverse
+	modeWrite
+)
+
+//returns true if mode travels back in history
+func (m mode) reverse() bool {
+	return m == modeBack || m == modeReverse || m == modeWrite
+}
+
+//Debugger runs scripts pausing on breakpoints and steps
+type Debugger struct {
+	compiler   compiler.Compiler
+	controller Controller
+	mode       mode
+	target     stack.Position
+	loop       stack.LoopElement
+	//cell watched by ReverseToWrite
+	cell    int
+	context *stack.Context
+	history *history
+	//condition results on previous step of history travel by breakpoint id
+	holds       map[int]bool
+	breakpoints map[int]Breakpoint
+	lastID      int
+	//offsets of commands marked with '#' in the script
+	markers map[int]bool
+	//id of the breakpoint hit last
+	hit int
+	//position of previous executed operation
+	previous stack.Position
+	aborted  bool
+	//functions scheduled from other goroutines
+	mutex      sync.Mutex
+	scheduled  []func(d *Debugger)
+	hasPending int32
+}
+
+//Marker character. next command after marker is a breakpoint
+const Marker = '#'
+
+/*
+create new debugger. execution is paused before the first operation
+*/
+func New(c compiler.Compiler, controller Controller) *Debugger {
+	return &Debugger{
+		compiler:    c,
+		controller:  controller,
+		mode:        modeStep,
+		breakpoints: make(map[int]Breakpoint),
+		markers:     make(map[int]bool),
+	}
+}
+
+/*
+run script with debugger. read byte data from reader and write outgoing bytes to writer.
+memory is configured by the compiler profile
+*/
+func (d *Debugger) Run(script io.Reader, reader io.Reader, writer io.Writer) error {
+	return d.RunContext(script, d.compiler.NewContext(reader, writer))
+}
+
+/*
+run script with debugger using prepared context
+*/
+func (d *Debugger) RunContext(script io.Reader, context *stack.Context) error {
+	d.context = context
+	previous := context.BeforeAction
+	context.BeforeAction = func(ctx *stack.Context) error {
+		if previous != nil {
+			if err := previous(ctx); err != nil {
+				return err
+			}
+		}
+		return d.before()
+	}
+	if d.history != nil {
+		after := context.AfterAction
+		context.AfterAction = func(ctx *stack.Context) error {
+			d.history.complete(ctx)
+			if after != nil {
+				return after(ctx)
+			}
+			return nil
+		}
+		if context.Reader != nil {
+			context.Reader = &inputRecorder{reader: context.Reader, history: d.history}
+		}
+	}
+	err := d.compiler.Run(compiler.WrapScript(script, &markerReader{reader: script, debugger: d}), context)
+	if d.aborted {
+		return ErrAborted
+	}
+	return err
+}
+
+//called before every operation
+func (d *Debugger) before() error {
+	if atomic.LoadInt32(&d.hasPending) != 0 {
+		d.runScheduled()
+		if d.aborted {
+			return ErrAborted
+		}
+	}
+	element := d.context.Stack.Current()
+	if d.history != nil {
+		d.history.begin(d.context, element)
+	}
+	position := element.Position()
+	reason, pause := d.pauseReason(element, d.hitLive)
+	d.previous = position
+	if !pause {
+		return nil
+	}
+	for {
+		d.mode = modeStep
+		if err := d.controller(d, reason); err != nil {
+			d.aborted = true
+			return err
+		}
+		if d.aborted {
+			return ErrAborted
+		}
+		if d.history == nil || (d.history.cursor == d.history.live && !d.mode.reverse()) {
+			return nil
+		}
+		if reason, pause = d.travel(); !pause {
+			return nil
+		}
+	}
+}
+
+//move through history according to the mode until pause reason is found or live step is reached
+func (d *Debugger) travel() (Reason, bool) {
+	h := d.history
+	d.holds = make(map[int]bool)
+	for id, b := range d.breakpoints {
+		if c, ok := b.(*ConditionBreakpoint); ok {
+			d.holds[id] = c.Holds(d.context)
+		}
+	}
+	for {
+		if d.mode.reverse() {
+			value, _ := d.context.GetCell(d.cell)
+			if !h.back(d.context) {
+				d.hit = 0
+				return ReasonHistory, true
+			}
+			if current, err := d.context.GetCell(d.cell); d.mode == modeWrite && err == nil && current != value {
+				d.hit = 0
+				return ReasonWrite, true
+			}
+		} else if !h.forward(d.context) {
+			return "", false
+		}
+		if previous := h.previous(); previous != nil {
+			d.previous = previous.Position()
+		}
+		reason, pause := d.pauseReason(h.current(), d.hitHistory)
+		if h.cursor == h.live {
+			d.previous = h.current().Position()
+		}
+		if pause {
+			return reason, true
+		}
+		if h.cursor == h.live && !d.mode.reverse() {
+			return "", false
+		}
+	}
+}
+
+//check breakpoint before live operation
+func (d *Debugger) hitLive(_ int, b Breakpoint, element stack.OperationalElement) bool {
+	return b.Hit(d, element)
+}
+
+//check breakpoint while traveling in history. conditions are checked by restored state without changing
+//their live state, so they are hit when become true in the direction of travel
+func (d *Debugger) hitHistory(id int, b Breakpoint, element stack.OperationalElement) bool {
+	c, ok := b.(*ConditionBreakpoint)
+	if !ok {
+		return b.Hit(d, element)
+	}
+	holds := c.Holds(d.context)
+	hit := holds && !d.holds[id]
+	d.holds[id] = holds
+	return hit
+}
+
+func (d *Debugger) pauseReason(element stack.OperationalElement, hit func(int, Breakpoint, stack.OperationalElement) bool) (Reason, bool) {
+	d.hit = 0
+	//all breakpoints are checked to keep state of conditions up to date
+	for id := 1; id <= d.lastID; id++ {
+		if b, found := d.breakpoints[id]; found && hit(id, b, element) && d.hit == 0 {
+			d.hit = id
+		}
+	}
+	if d.hit != 0 || d.markers[element.Position().Offset] {
+		return ReasonBreakpoint, true
+	}
+	switch d.mode {
+	case modeStep, modeBack:
+		return ReasonStep, true
+	case modePause:
+		return ReasonPause, true
+	case modeOver:
+		for loop := element.CurrentLoop(); loop != nil; loop = loop.GetPreviousLoop() {
+			if loop == d.loop {
+				return "", false
+			}
+		}
+		return ReasonStep, true
+	case modeCursor:
+		if element.Position().Line == d.target.Line && element.Position().Column == d.target.Column {
+			return ReasonCursor, true
+		}
+	}
+	return "", false
+}
+
+//execute single operation and pause
+func (d *Debugger) Step() {
+	d.mode = modeStep
+}
+
+//if current operation starts a loop, run the whole loop and pause after it. otherwise execute single operation
+func (d *Debugger) StepOver() {
+	element := d.Current()
+	if element == nil || element.Operation().Command() != "[" {
+		d.mode = modeStep
+		return
+	}
+	d.mode = modeOver
+	d.loop = element.CurrentLoop()
+}
+
+//run until the end of the loop enclosing current operation
+func (d *Debugger) StepOut() {
+	element := d.Current()
+	if element == nil || element.CurrentLoop() == nil {
+		d.mode = modeContinue
+		return
+	}
+	d.mode = modeOver
+	d.loop = element.CurrentLoop()
+}
+
+//run until breakpoint is hit
+func (d *Debugger) Continue() {
+	d.mode = modeContinue
+}
+
+//run until operation at provided line and column or until breakpoint is hit
+func (d *Debugger) RunTo(position stack.Position) {
+	d.mode = modeCursor
+	d.target = position
+}
+
+/*
+keep undo information of the last steps operations to travel back in history. non positive steps keeps all
+operations. changed cell is kept for every operation and all changed bytes for custom operations. must be called before Run
+*/
+func (d *Debugger) EnableHistory(steps int) {
+	d.history = newHistory(steps)
+}
+
+//restore state before the previous operation and pause. history must be enabled
+func (d *Debugger) StepBack() {
+	d.mode = modeBack
+}
+
+//travel back until breakpoint is hit or the oldest recorded step is reached. history must be enabled
+func (d *Debugger) ReverseContinue() {
+	d.mode = modeReverse
+}
+
+//travel back to the operation which changed value of the cell. history must be enabled
+func (d *Debugger) ReverseToWrite(cell int) {
+	d.mode = modeWrite
+	d.cell = cell
+}
+
+/*
+returns number of shown step and number of currently executed step starting from 0.
+they differ while debugger travels in history
+*/
+func (d *Debugger) StepNumber() (int, int) {
+	if d.history == nil {
+		return 0, 0
+	}
+	return d.history.cursor, d.history.live
+}
+
+//returns input consumed by the recorded operations before the shown step. history must be enabled
+func (d *Debugger) ConsumedInput() []byte {
+	if d.history == nil {
+		return nil
+	}
+	return d.history.input()
+}
+
+//stop execution. Run returns ErrAborted
+func (d *Debugger) Abort() {
+	d.aborted = true
+}
+
+/*
+schedule function to be called before the next operation. unlike other methods it is safe to call Do
+from another goroutine while script is running
+*/
+func (d *Debugger) Do(f func(d *Debugger)) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.scheduled = append(d.scheduled, f)
+	atomic.StoreInt32(&d.hasPending, 1)
+}
+
+//pause running script before the next operation. it is safe to call Pause from another goroutine
+func (d *Debugger) Pause() {
+	d.Do(func(d *Debugger) {
+		d.mode = modePause
+	})
+}
+
+func (d *Debugger) runScheduled() {
+	d.mutex.Lock()
+	scheduled := d.scheduled
+	d.scheduled = nil
+	atomic.StoreInt32(&d.hasPending, 0)
+	d.mutex.Unlock()
+	for _, f := range scheduled {
+		f(d)
+	}
+}
+
+//add breakpoint. returns breakpoint id
+func (d *Debugger) AddBreakpoint(b Breakpoint) int {
+	d.lastID++
+	d.breakpoints[d.lastID] = b
+	return d.lastID
+}
+
+//remove breakpoint by id
+func (d *Debugger) RemoveBreakpoint(id int) {
+	delete(d.breakpoints, id)
+}
+
+//returns breakpoints by id
+func (d *Debugger) Breakpoints() map[int]Breakpoint {
+	return d.breakpoints
+}
+
+//returns id of breakpoint which paused execution. returns 0 if execution was paused for other reason
+func (d *Debugger) HitBreakpoint() int {
+	return d.hit
+}
+
+//returns execution context
+func (d *Debugger) Context() *stack.Context {
+	return d.context
+}
+
+//returns operation which will be executed next. while traveling in history returns the shown operation
+func (d *Debugger) Current() stack.OperationalElement {
+	if d.context == nil {
+		return nil
+	}
+	if d.history != nil && len(d.history.entries) > 0 {
+		return d.history.current()
+	}
+	return d.context.Stack.Current()
+}
+
+//returns number of loops enclosing the operation returned by Current
+func (d *Debugger) Depth() int {
+	depth := 0
+	if element := d.Current(); element != nil {
+		for loop := element.CurrentLoop(); loop != nil; loop = loop.GetPreviousLoop() {
+			depth++
+		}
+	}
+	return depth
+}
+
+/*
+print memory cells around current cell. radius defines number of cells printed on each side
+*/
+func (d *Debugger) PrintTape(writer io.Writer, radius int) error {
+	ctx := d.context
+	from, to := ctx.CurrentIdx-radius, ctx.CurrentIdx+radius
+	if from < 0 {
+		from = 0
+	}
+	if to >= ctx.Cells() {
+		to = ctx.Cells() - 1
+	}
+	var indexes, values, pointer string
+	for i := from; i <= to; i++ {
+		value, _ := ctx.GetCell(i)
+		indexes += fmt.Sprintf("%5d", i)
+		values += fmt.Sprintf("%5d", value)
+		if i == ctx.CurrentIdx {
+			pointer += "    ^"
+		} else {
+			pointer += "     "
+		}
+	}
+	_, err := fmt.Fprintf(writer, "cell %s\nvalue%s\n     %s\n", indexes, values, strings.TrimRight(pointer, " "))
+	return err
+}
+
+//markerReader passes script to the compiler and records positions of commands marked with Marker
+type markerReader struct {
+	reader   io.Reader
+	debugger *Debugger
+	offset   int
+	marked   bool
+	//script header is not checked for markers. header is split when the first byte is read
+	split  bool
+	header int
+}
+
+func (r *markerReader) Read(p []byte) (int, error) {
+	if !r.split {
+		line, body, _, err := compiler.SplitHeader(r.reader)
+		if err != nil {
+			return 0, err
+		}
+		r.reader, r.header, r.split = io.MultiReader(strings.NewReader(line), body), len(line), true
+	}
+	n, err := r.reader.Read(p)
+	for _, b := range p[:n] {
+		if r.offset >= r.header {
+			r.mark(b, r.offset)
+		}
+		r.offset++
+	}
+	return n, err
+}
+
+func (r *markerReader) mark(b byte, offset int) {
+	command := r.debugger.compiler.IsCommand(stack.Command(b))
+	if b == Marker && !command {
+		r.marked = true
+	} else if r.marked && command {
+		r.debugger.markers[offset] = true
+		r.marked = false
+	}
+}
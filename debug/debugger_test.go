@@ -0,0 +1,252 @@
+package debug
+
+import (
+	"bytes"
+	compiler "github.com/gdtrp/brainfuck"
+	"github.com/gdtrp/brainfuck/stack"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+//pause records collected by test controller
+type pause struct {
+	reason   Reason
+	position string
+}
+
+//controller which records pauses and resumes execution with provided actions one by one
+func scripted(pauses *[]pause, actions ...func(d *Debugger)) Controller {
+	return func(d *Debugger, reason Reason) error {
+		*pauses = append(*pauses, pause{reason: reason, position: d.Current().Position().String()})
+		if len(actions) == 0 {
+			d.Continue()
+			return nil
+		}
+		actions[0](d)
+		actions = actions[1:]
+		return nil
+	}
+}
+
+func newCompiler(t *testing.T) compiler.Compiler {
+	c, err := compiler.New()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	return c
+}
+
+func step(d *Debugger)     { d.Step() }
+func stepOver(d *Debugger) { d.StepOver() }
+
+func TestDebugger_Step(t *testing.T) {
+	var pauses []pause
+	d := New(newCompiler(t), scripted(&pauses, step, step))
+	var buf bytes.Buffer
+	if err := d.Run(strings.NewReader("+\n+."), nil, &buf); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := []pause{{ReasonStep, "1:1"}, {ReasonStep, "2:1"}, {ReasonStep, "2:2"}}
+	if !equalPauses(pauses, expected) {
+		t.Fatalf("wrong pauses expected %v but was %v", expected, pauses)
+	}
+	if !bytes.Equal(buf.Bytes(), []byte{2}) {
+		t.Fatalf("wrong output %v", buf.Bytes())
+	}
+}
+
+func TestDebugger_Profile(t *testing.T) {
+	portable, _ := compiler.LookupProfile(compiler.ProfilePortable)
+	c, _ := compiler.New()
+	c.SetProfile(portable)
+	var pauses []pause
+	d := New(c, scripted(&pauses))
+	if err := d.Run(strings.NewReader("-"), nil, ioutil.Discard); err == nil || err.Error() != "cell underflow" {
+		t.Fatalf("underflow error expected but was %v", err)
+	}
+}
+
+func TestDebugger_StepOver(t *testing.T) {
+	var pauses []pause
+	d := New(newCompiler(t), scripted(&pauses, step, step, stepOver))
+	if err := d.Run(strings.NewReader("++[>+<-]>."), nil, &bytes.Buffer{}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := []pause{{ReasonStep, "1:1"}, {ReasonStep, "1:2"}, {ReasonStep, "1:3"}, {ReasonStep, "1:9"}}
+	if !equalPauses(pauses, expected) {
+		t.Fatalf("wrong pauses expected %v but was %v", expected, pauses)
+	}
+}
+
+func TestDebugger_StepOut(t *testing.T) {
+	var pauses []pause
+	d := New(newCompiler(t), scripted(&pauses, func(d *Debugger) {
+		d.RunTo(stack.Position{Line: 1, Column: 6})
+	}, (*Debugger).StepOut, (*Debugger).StepOut))
+	if err := d.Run(strings.NewReader("+[>+[-]<-]>."), nil, &bytes.Buffer{}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := []pause{{ReasonStep, "1:1"}, {ReasonCursor, "1:6"}, {ReasonStep, "1:8"}, {ReasonStep, "1:11"}}
+	if !equalPauses(pauses, expected) {
+		t.Fatalf("wrong pauses expected %v but was %v", expected, pauses)
+	}
+}
+
+func TestDebugger_Pause(t *testing.T) {
+	var pauses []pause
+	d := New(newCompiler(t), scripted(&pauses, (*Debugger).Abort))
+	d.Continue()
+	d.Pause()
+	if err := d.Run(strings.NewReader("+[]"), nil, &bytes.Buffer{}); err != ErrAborted {
+		t.Fatalf("aborted error expected but was %v", err)
+	}
+	if len(pauses) != 1 || pauses[0].reason != ReasonPause {
+		t.Fatalf("single pause expected but was %v", pauses)
+	}
+}
+
+func TestDebugger_Breakpoints(t *testing.T) {
+	var pauses []pause
+	d := New(newCompiler(t), scripted(&pauses))
+	d.AddBreakpoint(PositionBreakpoint{Line: 2})
+	condition, err := ParseBreakpoint("cell 1 == 2")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	d.AddBreakpoint(condition)
+	d.Continue()
+	if err := d.Run(strings.NewReader("+++[>+<-]\n>."), nil, &bytes.Buffer{}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := []pause{{ReasonBreakpoint, "1:7"}, {ReasonBreakpoint, "2:1"}}
+	if !equalPauses(pauses, expected) {
+		t.Fatalf("wrong pauses expected %v but was %v", expected, pauses)
+	}
+}
+
+func TestDebugger_Markers(t *testing.T) {
+	var pauses []pause
+	d := New(newCompiler(t), scripted(&pauses))
+	d.Continue()
+	if err := d.Run(strings.NewReader("++[># +<-]"), nil, &bytes.Buffer{}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := []pause{{ReasonBreakpoint, "1:7"}, {ReasonBreakpoint, "1:7"}}
+	if !equalPauses(pauses, expected) {
+		t.Fatalf("wrong pauses expected %v but was %v", expected, pauses)
+	}
+}
+
+func TestDebugger_MarkersHeader(t *testing.T) {
+	for _, test := range []struct {
+		script   string
+		expected []pause
+	}{
+		{"#!bf cells=16\n+#+", []pause{{ReasonStep, "2:1"}, {ReasonBreakpoint, "2:3"}}},
+		{"#!bfx\n+#+", []pause{{ReasonBreakpoint, "2:1"}, {ReasonBreakpoint, "2:3"}}},
+		{"#!+", []pause{{ReasonBreakpoint, "1:3"}}},
+	} {
+		var pauses []pause
+		d := New(newCompiler(t), scripted(&pauses, (*Debugger).Continue))
+		if err := d.Run(strings.NewReader(test.script), nil, &bytes.Buffer{}); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if !equalPauses(pauses, test.expected) {
+			t.Fatalf("%q: wrong pauses expected %v but was %v", test.script, test.expected, pauses)
+		}
+	}
+}
+
+func TestDebugger_Preprocessed(t *testing.T) {
+	c := newCompiler(t)
+	c.SetIncludePath(fstest.MapFS{"lib.bf": {Data: []byte("+\n+")}})
+	var pauses []pause
+	d := New(c, scripted(&pauses, step, step))
+	if err := d.Run(c.Preprocess(strings.NewReader("#include \"lib.bf\"\n.")), nil, &bytes.Buffer{}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := []pause{{ReasonStep, "lib.bf:1:1"}, {ReasonStep, "lib.bf:2:1"}, {ReasonStep, "2:1"}}
+	if !equalPauses(pauses, expected) {
+		t.Fatalf("wrong pauses expected %v but was %v", expected, pauses)
+	}
+}
+
+func TestDebugger_RunTo(t *testing.T) {
+	var pauses []pause
+	d := New(newCompiler(t), scripted(&pauses, func(d *Debugger) {
+		d.RunTo(stack.Position{Line: 1, Column: 5})
+	}))
+	if err := d.Run(strings.NewReader("++[>+<-]"), nil, &bytes.Buffer{}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := []pause{{ReasonStep, "1:1"}, {ReasonCursor, "1:5"}}
+	if !equalPauses(pauses, expected) {
+		t.Fatalf("wrong pauses expected %v but was %v", expected, pauses)
+	}
+}
+
+func TestDebugger_Abort(t *testing.T) {
+	d := New(newCompiler(t), func(d *Debugger, reason Reason) error {
+		d.Abort()
+		return nil
+	})
+	if err := d.Run(strings.NewReader("+."), nil, &bytes.Buffer{}); err != ErrAborted {
+		t.Fatalf("aborted error expected but was %v", err)
+	}
+}
+
+func TestConsole(t *testing.T) {
+	commands := "b cell == 2\nl\nc\np 1\nq\n"
+	var output bytes.Buffer
+	d := New(newCompiler(t), Console(strings.NewReader(commands), &output))
+	if err := d.Run(strings.NewReader(">+++"), nil, &bytes.Buffer{}); err != ErrAborted {
+		t.Fatalf("aborted error expected but was %v", err)
+	}
+	for _, expected := range []string{
+		"paused (step) at 1:1 before '>', loop depth 0",
+		"breakpoint 1: cell == 2",
+		"paused (breakpoint 1) at 1:4 before '+', loop depth 0",
+		"cell     0    1    2\nvalue    0    2    0\n              ^\n",
+	} {
+		if !strings.Contains(output.String(), expected) {
+			t.Fatalf("output should contain %q but was %q", expected, output.String())
+		}
+	}
+}
+
+func TestParseBreakpoint(t *testing.T) {
+	for text, expected := range map[string]string{
+		"3":            "line 3",
+		"3:4":          "position 3:4",
+		"cell 3 == 10": "cell 3 == 10",
+		"cell >= 1":    "cell >= 1",
+		"ptr != 0":     "ptr != 0",
+	} {
+		b, err := ParseBreakpoint(text)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if b.String() != expected {
+			t.Errorf("wrong breakpoint expected %v but was %v", expected, b)
+		}
+	}
+	for _, text := range []string{"", "0", "3:x", "cell 3 = 1", "ptr 1 == 1", "cell -1 == 1"} {
+		if _, err := ParseBreakpoint(text); err == nil {
+			t.Errorf("error expected for %q", text)
+		}
+	}
+}
+
+func equalPauses(a []pause, b []pause) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,178 @@
+package debug
+
+import (
+	"github.com/gdtrp/brainfuck/stack"
+	"io"
+)
+
+//entry contains undo and redo information of single executed operation
+type entry struct {
+	element stack.OperationalElement
+	//pointer before and after the operation
+	pointer int
+	next    int
+	//value of the cell at pointer before and after the operation
+	before uint32
+	after  uint32
+	//bytes read from the input by the operation
+	input []byte
+	//custom operation can change any cell. its changes are recorded byte by byte
+	custom  bool
+	changes []change
+}
+
+//change of memory byte made by custom operation
+type change struct {
+	offset int
+	before byte
+	after  byte
+}
+
+/*
+history records undo and redo information of executed operations. steps in both directions apply cell values
+of operations or bytes changed by custom operations, copies of memory are not kept
+*/
+type history struct {
+	limit    int
+	defaults map[stack.Command]bool
+	//step number of the first entry
+	first   int
+	entries []entry
+	//step of currently executed operation
+	live int
+	//step shown to controller. differs from live while debugger travels back in history
+	cursor int
+	//memory before the running custom operation. it is reused, so only changed bytes are kept in history
+	scratch []byte
+}
+
+func newHistory(limit int) *history {
+	defaults := make(map[stack.Command]bool)
+	for _, o := range stack.GetDefaultOperations() {
+		defaults[o.Command()] = true
+	}
+	return &history{limit: limit, defaults: defaults}
+}
+
+//record the start of operation popped from the stack
+func (h *history) begin(ctx *stack.Context, element stack.OperationalElement) {
+	h.live = h.first + len(h.entries)
+	h.entries = append(h.entries, entry{
+		element: element,
+		pointer: ctx.CurrentIdx,
+		before:  ctx.GetCurrentCell(),
+		custom:  !h.defaults[element.Operation().Command()],
+	})
+	if h.entries[len(h.entries)-1].custom {
+		h.scratch = append(h.scratch[:0], ctx.Memory...)
+	}
+	h.cursor = h.live
+	h.trim()
+}
+
+//record the result of successfully executed operation
+func (h *history) complete(ctx *stack.Context) {
+	e := &h.entries[len(h.entries)-1]
+	e.after, _ = ctx.GetCell(e.pointer)
+	e.next = ctx.CurrentIdx
+	if e.custom {
+		//memory can grow during the operation, added bytes were zero
+		for i, b := range ctx.Memory {
+			var before byte
+			if i < len(h.scratch) {
+				before = h.scratch[i]
+			}
+			if b != before {
+				e.changes = append(e.changes, change{offset: i, before: before, after: b})
+			}
+		}
+	}
+}
+
+//drop the oldest entries exceeding the limit
+func (h *history) trim() {
+	if h.limit <= 0 || len(h.entries) <= h.limit {
+		return
+	}
+	drop := len(h.entries) - h.limit
+	h.entries = h.entries[drop:]
+	h.first += drop
+}
+
+//returns element of the operation at cursor
+func (h *history) current() stack.OperationalElement {
+	return h.entries[h.cursor-h.first].element
+}
+
+//returns element of the operation before cursor. returns nil at the oldest recorded step
+func (h *history) previous() stack.OperationalElement {
+	if h.cursor == h.first {
+		return nil
+	}
+	return h.entries[h.cursor-1-h.first].element
+}
+
+//move cursor one step back restoring the state. returns false at the oldest recorded step
+func (h *history) back(ctx *stack.Context) bool {
+	if h.cursor == h.first {
+		return false
+	}
+	h.cursor--
+	e := h.entries[h.cursor-h.first]
+	if e.custom {
+		for _, c := range e.changes {
+			ctx.Memory[c.offset] = c.before
+		}
+	} else {
+		ctx.SetCell(e.pointer, e.before)
+	}
+	ctx.CurrentIdx = e.pointer
+	return true
+}
+
+//move cursor one step forward restoring the state. returns false at the live step
+func (h *history) forward(ctx *stack.Context) bool {
+	if h.cursor == h.live {
+		return false
+	}
+	e := h.entries[h.cursor-h.first]
+	h.cursor++
+	apply(ctx, e)
+	return true
+}
+
+//redo recorded operation
+func apply(ctx *stack.Context, e entry) {
+	if e.custom {
+		for _, c := range e.changes {
+			ctx.Memory[c.offset] = c.after
+		}
+	} else {
+		ctx.SetCell(e.pointer, e.after)
+	}
+	ctx.CurrentIdx = e.next
+}
+
+//returns input consumed by recorded operations before cursor
+func (h *history) input() []byte {
+	var result []byte
+	for _, e := range h.entries[:h.cursor-h.first] {
+		result = append(result, e.input...)
+	}
+	return result
+}
+
+//inputRecorder adds bytes read by currently executed operation to its history entry
+type inputRecorder struct {
+	reader  io.Reader
+	history *history
+}
+
+func (r *inputRecorder) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 && len(r.history.entries) > 0 {
+		e := &r.history.entries[len(r.history.entries)-1]
+		e.input = append(e.input, p[:n]...)
+	}
+	return n, err
+}
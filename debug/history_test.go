@@ -0,0 +1,215 @@
+package debug
+
+import (
+	"bytes"
+	"fmt"
+	compiler "github.com/gdtrp/brainfuck"
+	"github.com/gdtrp/brainfuck/stack"
+	"strings"
+	"testing"
+)
+
+//controller which records pauses with pointer and the first cells
+func traveling(pauses *[]string, actions ...func(d *Debugger)) Controller {
+	return func(d *Debugger, reason Reason) error {
+		ctx := d.Context()
+		*pauses = append(*pauses, fmt.Sprintf("%s %s ptr %d %v", reason, d.Current().Position(), ctx.CurrentIdx, ctx.Memory[:3]))
+		if len(actions) == 0 {
+			d.Continue()
+			return nil
+		}
+		actions[0](d)
+		actions = actions[1:]
+		return nil
+	}
+}
+
+func runTo(line int, column int) func(d *Debugger) {
+	return func(d *Debugger) {
+		d.RunTo(stack.Position{Line: line, Column: column})
+	}
+}
+
+func equalStrings(a []string, b []string) bool {
+	return strings.Join(a, "\n") == strings.Join(b, "\n")
+}
+
+func TestDebugger_StepBack(t *testing.T) {
+	var pauses []string
+	d := New(newCompiler(t), traveling(&pauses, step, step, (*Debugger).StepBack, (*Debugger).StepBack, step))
+	d.EnableHistory(100)
+	var buf bytes.Buffer
+	if err := d.Run(strings.NewReader("+>+."), nil, &buf); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := []string{
+		"step 1:1 ptr 0 [0 0 0]",
+		"step 1:2 ptr 0 [1 0 0]",
+		"step 1:3 ptr 1 [1 0 0]",
+		"step 1:2 ptr 0 [1 0 0]",
+		"step 1:1 ptr 0 [0 0 0]",
+		"step 1:2 ptr 0 [1 0 0]",
+	}
+	if !equalStrings(pauses, expected) {
+		t.Fatalf("wrong pauses expected\n%v\nbut was\n%v", strings.Join(expected, "\n"), strings.Join(pauses, "\n"))
+	}
+	if !bytes.Equal(buf.Bytes(), []byte{1}) {
+		t.Fatalf("wrong output %v", buf.Bytes())
+	}
+}
+
+func TestDebugger_ReverseContinue(t *testing.T) {
+	var pauses []string
+	d := New(newCompiler(t), traveling(&pauses, (*Debugger).Continue, (*Debugger).Continue, runTo(1, 9),
+		func(d *Debugger) { d.ReverseToWrite(1) }, (*Debugger).ReverseContinue, (*Debugger).ReverseContinue,
+		(*Debugger).ReverseContinue))
+	d.EnableHistory(100)
+	d.AddBreakpoint(PositionBreakpoint{Line: 1, Column: 3})
+	var buf bytes.Buffer
+	if err := d.Run(strings.NewReader(",[->+<]>."), strings.NewReader("\x02"), &buf); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := []string{
+		"step 1:1 ptr 0 [0 0 0]",
+		"breakpoint 1:3 ptr 0 [2 0 0]",
+		"breakpoint 1:3 ptr 0 [1 1 0]",
+		"cursor 1:9 ptr 1 [0 2 0]",
+		"write 1:5 ptr 1 [0 1 0]",
+		"breakpoint 1:3 ptr 0 [1 1 0]",
+		"breakpoint 1:3 ptr 0 [2 0 0]",
+		"history 1:1 ptr 0 [0 0 0]",
+		//breakpoints are hit again while replaying history forward
+		"breakpoint 1:3 ptr 0 [2 0 0]",
+		"breakpoint 1:3 ptr 0 [1 1 0]",
+	}
+	if !equalStrings(pauses, expected) {
+		t.Fatalf("wrong pauses expected\n%v\nbut was\n%v", strings.Join(expected, "\n"), strings.Join(pauses, "\n"))
+	}
+	if !bytes.Equal(buf.Bytes(), []byte{2}) {
+		t.Fatalf("wrong output %v", buf.Bytes())
+	}
+	if input := d.ConsumedInput(); len(input) != 1 || input[0] != 2 {
+		t.Fatalf("wrong consumed input %v", input)
+	}
+}
+
+func TestDebugger_HistoryCustomOperation(t *testing.T) {
+	c, _ := compiler.New(double{})
+	var pauses []string
+	d := New(c, traveling(&pauses, runTo(1, 4), (*Debugger).StepBack, (*Debugger).StepBack, step, step))
+	d.EnableHistory(100)
+	if err := d.Run(strings.NewReader("+**+"), nil, &bytes.Buffer{}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := []string{
+		"step 1:1 ptr 0 [0 0 0]",
+		"cursor 1:4 ptr 0 [4 0 0]",
+		"step 1:3 ptr 0 [2 0 0]",
+		"step 1:2 ptr 0 [1 0 0]",
+		"step 1:3 ptr 0 [2 0 0]",
+		"step 1:4 ptr 0 [4 0 0]",
+	}
+	if !equalStrings(pauses, expected) {
+		t.Fatalf("wrong pauses expected\n%v\nbut was\n%v", strings.Join(expected, "\n"), strings.Join(pauses, "\n"))
+	}
+}
+
+func TestDebugger_HistoryCustomOperationsBounded(t *testing.T) {
+	c, _ := compiler.New(double{})
+	var pauses []string
+	d := New(c, traveling(&pauses, runTo(2, 1), (*Debugger).ReverseContinue))
+	d.EnableHistory(1000)
+	if err := d.Run(strings.NewReader("+"+strings.Repeat("*", 2500)+"\n."), nil, &bytes.Buffer{}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(d.history.entries) != 1000 {
+		t.Fatalf("history is not bounded: %v entries", len(d.history.entries))
+	}
+	for _, e := range d.history.entries {
+		if len(e.changes) > 1 {
+			t.Fatalf("only changed byte should be recorded but was %v", e.changes)
+		}
+	}
+}
+
+func TestDebugger_HistoryLimit(t *testing.T) {
+	var pauses []string
+	d := New(newCompiler(t), traveling(&pauses, runTo(1, 11), (*Debugger).ReverseContinue))
+	d.EnableHistory(3000)
+	var buf bytes.Buffer
+	if err := d.Run(strings.NewReader("-[>-[-]<-]."), nil, &buf); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(pauses) != 3 || !strings.HasPrefix(pauses[2], "history ") {
+		t.Fatalf("the oldest recorded step expected but was %v", pauses)
+	}
+	if len(d.history.entries) != 3000 {
+		t.Fatalf("history is not bounded: %v entries", len(d.history.entries))
+	}
+	if !bytes.Equal(buf.Bytes(), []byte{0}) {
+		t.Fatalf("wrong output %v", buf.Bytes())
+	}
+}
+
+func TestDebugger_HistoryWideCells(t *testing.T) {
+	var memory []string
+	d := New(newCompiler(t), func(d *Debugger, reason Reason) error {
+		ctx := d.Context()
+		memory = append(memory, fmt.Sprint(ctx.CurrentIdx, ctx.Memory[:6]))
+		switch len(memory) {
+		case 1:
+			d.RunTo(stack.Position{Line: 2, Column: 9})
+		case 2, 3:
+			d.StepBack()
+		default:
+			d.Continue()
+		}
+		return nil
+	})
+	d.EnableHistory(100)
+	if err := d.Run(strings.NewReader("#!bf cells=16\n>>+++<<+."), nil, &bytes.Buffer{}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := []string{"0 [0 0 0 0 0 0]", "0 [1 0 0 0 3 0]", "0 [0 0 0 0 3 0]", "1 [0 0 0 0 3 0]"}
+	if !equalStrings(memory, expected) {
+		t.Fatalf("wrong memory expected %v but was %v", expected, memory)
+	}
+	var tape bytes.Buffer
+	d.PrintTape(&tape, 2)
+	if !strings.Contains(tape.String(), "value    1    0    3\n") {
+		t.Fatalf("wrong tape %q", tape.String())
+	}
+}
+
+type double struct{}
+
+func (double) Command() stack.Command {
+	return "*"
+}
+func (double) Action() func(ctx *stack.Context) error {
+	return func(ctx *stack.Context) error {
+		ctx.SetCurrentByte(ctx.GetCurrentByte() * 2)
+		return nil
+	}
+}
+
+func TestConsole_History(t *testing.T) {
+	commands := "bs\nu 1:3\nw 1\nbs\nrc\nq\n"
+	var output bytes.Buffer
+	d := New(newCompiler(t), Console(strings.NewReader(commands), &output))
+	d.EnableHistory(10)
+	if err := d.Run(strings.NewReader(">++"), nil, &bytes.Buffer{}); err != ErrAborted {
+		t.Fatalf("aborted error expected but was %v", err)
+	}
+	for _, expected := range []string{
+		"paused (history) at 1:1 before '>', loop depth 0\n",
+		"paused (cursor) at 1:3 before '+', loop depth 0\n",
+		"paused (write) at 1:2 before '+', loop depth 0, history step 1 of 2\n",
+		"paused (step) at 1:1 before '>', loop depth 0, history step 0 of 2\n",
+		"paused (history) at 1:1 before '>', loop depth 0, history step 0 of 2\n",
+	} {
+		if !strings.Contains(output.String(), expected) {
+			t.Fatalf("output should contain %q but was %q", expected, output.String())
+		}
+	}
+}
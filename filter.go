@@ -0,0 +1,45 @@
+package compiler
+
+import (
+	"github.com/gdtrp/brainfuck/stack"
+	"io"
+)
+
+//Filter is output of the script executed in the background. see NewFilter
+type Filter struct {
+	reader  *io.PipeReader
+	input   io.Reader
+	context *stack.Context
+}
+
+/*
+run script in the background reading data from input and return its output. writing operation of the script waits
+until the output is read. error of the script is returned by Read after all output is read, io.EOF is returned
+if the script is finished successfully. memory is configured by the compiler profile
+*/
+func NewFilter(c Compiler, script io.Reader, input io.Reader) *Filter {
+	reader, writer := io.Pipe()
+	f := &Filter{reader: reader, input: input, context: c.NewContext(input, writer)}
+	go func() {
+		writer.CloseWithError(c.Run(script, f.context))
+	}()
+	return f
+}
+
+//read output of the script
+func (f *Filter) Read(p []byte) (int, error) {
+	return f.reader.Read(p)
+}
+
+/*
+stop the script before its next operation and close the input if it implements io.Closer.
+reading the output returns io.ErrClosedPipe after the call
+*/
+func (f *Filter) Close() error {
+	f.context.Suspend()
+	f.reader.Close()
+	if closer, ok := f.input.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package compiler
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+//reader recording whether it is closed
+type closingReader struct {
+	io.Reader
+	closed bool
+}
+
+func (r *closingReader) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestNewFilter(t *testing.T) {
+	c, _ := New()
+	//increments every input byte
+	increment := strings.NewReader("#!bf eof=0\n,[+.,]")
+	filter := NewFilter(c, increment, NewFilter(c, strings.NewReader("#!bf eof=0\n,[.,]"), strings.NewReader("HAL")))
+	result, err := ioutil.ReadAll(filter)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if string(result) != "IBM" {
+		t.Fatalf("wrong output %q", result)
+	}
+}
+
+func TestNewFilter_Error(t *testing.T) {
+	c, _ := New()
+	result, err := ioutil.ReadAll(NewFilter(c, strings.NewReader("+.<"), strings.NewReader("")))
+	if err == nil || err.Error() != "index is out of range" {
+		t.Fatalf("script error expected but was %v", err)
+	}
+	if string(result) != "\x01" {
+		t.Fatalf("output before error expected but was %q", result)
+	}
+}
+
+func TestFilter_Close(t *testing.T) {
+	c, _ := New()
+	input := &closingReader{Reader: strings.NewReader("")}
+	//endless output
+	filter := NewFilter(c, strings.NewReader("+[.]"), input)
+	buffer := make([]byte, 10)
+	if _, err := io.ReadFull(filter, buffer); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := filter.Close(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !input.closed {
+		t.Fatalf("input should be closed")
+	}
+	if _, err := filter.Read(buffer); err != io.ErrClosedPipe {
+		t.Fatalf("closed pipe error expected but was %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package compiler
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+/*
+format script by indenting every line with tabs according to loop nesting depth. lines starting with closing bracket
+are placed on the depth of the loop they close. trailing whitespaces are removed, script content is not changed otherwise
+*/
+func (c Compiler) Format(script io.Reader, writer io.Writer) error {
+	reader := bufio.NewReader(script)
+	depth := 0
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if line == "" && err == io.EOF {
+			return nil
+		}
+		newline := strings.HasSuffix(line, "\n")
+		content := strings.TrimSpace(line)
+		indent := depth
+		if strings.HasPrefix(content, "]") && indent > 0 {
+			indent--
+		}
+		for _, token := range []byte(content) {
+			switch token {
+			case '[':
+				depth++
+			case ']':
+				if depth > 0 {
+					depth--
+				}
+			}
+		}
+		if content != "" {
+			content = strings.Repeat("\t", indent) + content
+		}
+		if newline {
+			content += "\n"
+		}
+		if _, err := io.WriteString(writer, content); err != nil {
+			return err
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
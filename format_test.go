@@ -0,0 +1,36 @@
+package compiler
+
+import (
+	"bytes"
+	"testing"
+)
+
+var formatScripts = []struct {
+	name   string
+	script string
+	result string
+}{
+	{"single line", "++[->+<]>.", "++[->+<]>."},
+	{"nested loops", "+[\n>[\n  -\n]\n]  \n.\n", "+[\n\t>[\n\t\t-\n\t]\n]\n.\n"},
+	{"closing bracket with commands", "[\n-\n]+[\n-]\n", "[\n\t-\n]+[\n\t-]\n"},
+	{"empty lines are kept", "[\n\n  \n]", "[\n\n\n]"},
+	{"unbalanced brackets", "]\n[\n-", "]\n[\n\t-"},
+}
+
+func TestCompiler_Format(t *testing.T) {
+	compiler, error := New()
+	if error != nil {
+		t.Fatalf("error should be nil")
+	}
+	for _, test := range formatScripts {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if error := compiler.Format(bytes.NewBufferString(test.script), &buf); error != nil {
+				t.Fatalf("unexpected error %v", error)
+			}
+			if buf.String() != test.result {
+				t.Fatalf("wrong result expected %q but was %q", test.result, buf.String())
+			}
+		})
+	}
+}
@@ -1,3 +1,3 @@
 module github.com/gdtrp/brainfuck
 
-go 1.13
+go 1.16
@@ -0,0 +1,346 @@
+package compiler
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/gdtrp/brainfuck/stack"
+	"io"
+	"strconv"
+	"strings"
+)
+
+//prefix of the script header line
+const headerPrefix = "#!bf"
+
+//names of supported header pragmas
+const (
+	PragmaCells   = "cells"
+	PragmaEOF     = "eof"
+	PragmaTape    = "tape"
+	PragmaDialect = "dialect"
+)
+
+/*
+set pragmas which script headers are allowed to override. header with any other pragma stops execution
+with SyntaxError. by default all pragmas are allowed. compilers copied before the call are not changed
+*/
+func (c *Compiler) AllowPragmas(names ...string) {
+	c.pragmas = make(map[string]bool)
+	for _, name := range names {
+		c.pragmas[name] = true
+	}
+}
+
+//pragma is a name=value pair of the script header
+type pragma struct {
+	name     string
+	value    string
+	position stack.Position
+}
+
+/*
+header is the optional first line of the script starting with "#!bf" and followed by pragmas, for example
+"#!bf cells=16 eof=0 tape=grow dialect=ook". supported pragmas:
+
+	cells   - cell width in bits: 8, 16 or 32
+	eof     - value stored by input at the end of input: 0, -1 or unchanged
+	tape    - number of memory cells or grow for memory growing when pointer moves past its end
+	dialect - script language: bf or ook
+*/
+type header struct {
+	pragmas []pragma
+	//header line including line break
+	line string
+}
+
+//returns header line without the dialect pragma. returns empty string if no other pragma is set
+func (h *header) withoutDialect() string {
+	var fields []string
+	for _, p := range h.pragmas {
+		if p.name != PragmaDialect {
+			fields = append(fields, p.name+"="+p.value)
+		}
+	}
+	if len(fields) == 0 {
+		return ""
+	}
+	return headerPrefix + " " + strings.Join(fields, " ") + "\n"
+}
+
+/*
+split the script into its header line and the rest. returns header line including line break or empty string
+if the script has no header, reader of the rest of the script and position of its first byte.
+bytes read while looking for the header are returned by the reader if there is no header
+*/
+func SplitHeader(script io.Reader) (string, io.Reader, stack.Position, error) {
+	start := stack.Position{Line: 1, Column: 1}
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := script.Read(b)
+		if n == 0 {
+			if err == nil {
+				continue
+			}
+			if err != io.EOF || len(line) < len(headerPrefix) {
+				return "", io.MultiReader(bytes.NewReader(line), script), start, ignoreEOF(err)
+			}
+			break
+		}
+		if len(line) < len(headerPrefix) && b[0] != headerPrefix[len(line)] ||
+			len(line) == len(headerPrefix) && !isSpace(b[0]) {
+			line = append(line, b[0])
+			return "", io.MultiReader(bytes.NewReader(line), script), start, nil
+		}
+		line = append(line, b[0])
+		if b[0] == '\n' {
+			break
+		}
+	}
+	if line[len(line)-1] != '\n' {
+		//script contains header only
+		return string(line), script, stack.Position{Offset: len(line), Line: 1, Column: len(line) + 1}, nil
+	}
+	return string(line), script, stack.Position{Offset: len(line), Line: 2, Column: 1}, nil
+}
+
+/*
+read header of the script. returns reader of the rest of the script translated to commands by the dialect
+and scanner position of its first byte
+*/
+func (c Compiler) readHeader(script io.Reader) (*header, io.Reader, stack.Position, error) {
+	line, body, start, err := SplitHeader(script)
+	if err != nil || line == "" {
+		return nil, body, start, err
+	}
+	h, err := parseHeader(line)
+	if err != nil {
+		return nil, nil, stack.Position{Line: 1, Column: 1}, err
+	}
+	for _, p := range h.pragmas {
+		if p.name == PragmaDialect && p.value == "ook" {
+			body = &ookReader{reader: body}
+		}
+	}
+	return h, body, start, nil
+}
+
+func ignoreEOF(err error) error {
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+//parse header line and validate values of pragmas
+func parseHeader(line string) (*header, error) {
+	h := &header{line: line}
+	column := len(headerPrefix)
+	for _, field := range strings.FieldsFunc(line[len(headerPrefix):], func(r rune) bool { return r < 128 && isSpace(byte(r)) }) {
+		column = strings.Index(line[column:], field) + column
+		position := stack.Position{Offset: column, Line: 1, Column: column + 1}
+		column += len(field)
+		i := strings.IndexByte(field, '=')
+		if i <= 0 {
+			return nil, &SyntaxError{Position: position, Message: fmt.Sprintf("wrong pragma %q", field)}
+		}
+		p := pragma{name: field[:i], value: field[i+1:], position: position}
+		valid := false
+		switch p.name {
+		case PragmaCells:
+			valid = p.value == "8" || p.value == "16" || p.value == "32"
+		case PragmaEOF:
+			valid = p.value == "0" || p.value == "-1" || p.value == "unchanged"
+		case PragmaTape:
+			n, err := strconv.Atoi(p.value)
+			valid = p.value == "grow" || err == nil && n > 0
+		case PragmaDialect:
+			valid = p.value == "bf" || p.value == "ook"
+		default:
+			return nil, &SyntaxError{Position: position, Message: fmt.Sprintf("unknown pragma %v", p.name)}
+		}
+		if !valid {
+			return nil, &SyntaxError{Position: position, Message: fmt.Sprintf("wrong value of pragma %v: %q", p.name, p.value)}
+		}
+		h.pragmas = append(h.pragmas, p)
+	}
+	return h, nil
+}
+
+/*
+configure context by pragmas allowed by the compiler policy. memory is reallocated if cell width or tape size is set,
+pointer position is kept if it is inside of the new memory
+*/
+func (c Compiler) apply(h *header, context *stack.Context) error {
+	width, cells, resize := context.CellWidth, context.Cells(), false
+	if width == 0 {
+		width = 1
+	}
+	//the last pragma changing memory size
+	var size pragma
+	for _, p := range h.pragmas {
+		if c.pragmas != nil && !c.pragmas[p.name] {
+			return &SyntaxError{Position: p.position, Message: fmt.Sprintf("pragma %v is not allowed", p.name)}
+		}
+		switch p.name {
+		case PragmaCells:
+			bits, _ := strconv.Atoi(p.value)
+			width, resize, size = bits/8, true, p
+		case PragmaEOF:
+			switch p.value {
+			case "0":
+				context.EOF = stack.EOFZero
+			case "-1":
+				context.EOF = stack.EOFMinusOne
+			default:
+				context.EOF = stack.EOFUnchanged
+			}
+		case PragmaTape:
+			if p.value == "grow" {
+				context.Grow = true
+			} else {
+				cells, _ = strconv.Atoi(p.value)
+				resize, size = true, p
+			}
+		}
+	}
+	if !resize {
+		return nil
+	}
+	if cells > stack.MaxMemorySize/width {
+		return &SyntaxError{Position: size.position,
+			Message: fmt.Sprintf("memory size is limited to %d bytes", stack.MaxMemorySize)}
+	}
+	pointer := context.CurrentIdx
+	if err := context.Configure(width, cells); err != nil {
+		return err
+	}
+	if pointer < cells {
+		//initial pointer position set by profile is kept
+		context.CurrentIdx = pointer
+	}
+	return nil
+}
+
+/*
+returns scanner of the script after its header. header pragmas are applied to the context
+*/
+func (c Compiler) open(script io.Reader, context *stack.Context) (*scanner, error) {
+	h, s, err := c.split(script)
+	if err != nil {
+		return nil, err
+	}
+	if h != nil {
+		if err := c.apply(h, context); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+//returns header of the script and scanner of the rest of it
+func (c Compiler) split(script io.Reader) (*header, *scanner, error) {
+	h, body, position, err := c.readHeader(script)
+	if err != nil {
+		return nil, nil, err
+	}
+	s := c.scan(body)
+	s.position = position
+	s.locator, _ = script.(locator)
+	return h, s, nil
+}
+
+//ook commands by punctuation of the word pair
+var ookCommands = map[string]byte{
+	"..": '+', "!!": '-', ".?": '>', "?.": '<', "!.": '.', ".!": ',', "!?": '[', "?!": ']',
+}
+
+/*
+ookReader translates Ook! script to commands keeping its length. command is placed at the first byte of the word pair,
+line breaks are kept and all other bytes are replaced by spaces
+*/
+type ookReader struct {
+	reader io.Reader
+	//bytes read starting from the first word of the incomplete pair
+	pending []byte
+	//translated bytes
+	ready []byte
+	//matched length of the current word and punctuation of the first word of the pair
+	word  int
+	first byte
+	err   error
+}
+
+func (o *ookReader) Read(p []byte) (int, error) {
+	buffer := make([]byte, len(p))
+	for len(o.ready) == 0 && o.err == nil {
+		n, err := o.reader.Read(buffer)
+		for _, b := range buffer[:n] {
+			o.translate(b)
+		}
+		if err == io.EOF {
+			o.flush(len(o.pending), 0)
+			o.err = err
+		} else if err != nil && len(o.ready) == 0 {
+			//other errors are not kept, reading can be retried
+			return 0, err
+		}
+	}
+	if len(o.ready) == 0 {
+		return 0, o.err
+	}
+	n := copy(p, o.ready)
+	o.ready = o.ready[n:]
+	return n, nil
+}
+
+func (o *ookReader) translate(b byte) {
+	if o.first == 0 && o.word == 0 && b != 'O' {
+		o.ready = append(o.ready, blank(b))
+		return
+	}
+	o.pending = append(o.pending, b)
+	switch {
+	case o.word < 3 && b == "Ook"[o.word]:
+		o.word++
+	case o.word == 3 && (b == '.' || b == '?' || b == '!'):
+		o.word = 0
+		if o.first == 0 {
+			o.first = b
+			return
+		}
+		command := ookCommands[string([]byte{o.first, b})]
+		o.first = 0
+		o.flush(len(o.pending), command)
+	case b == 'O':
+		o.word = 1
+		if o.first == 0 {
+			o.flush(len(o.pending)-1, 0)
+		}
+	default:
+		o.word = 0
+		if o.first == 0 {
+			o.flush(len(o.pending), 0)
+		}
+	}
+}
+
+//move n pending bytes to translated ones. the first byte is replaced by command if it is not zero
+func (o *ookReader) flush(n int, command byte) {
+	for i, b := range o.pending[:n] {
+		if i == 0 && command != 0 {
+			b = command
+		} else {
+			b = blank(b)
+		}
+		o.ready = append(o.ready, b)
+	}
+	o.pending = append(o.pending[:0], o.pending[n:]...)
+}
+
+func blank(b byte) byte {
+	if b == '\n' {
+		return b
+	}
+	return ' '
+}
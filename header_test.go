@@ -0,0 +1,163 @@
+package compiler
+
+import (
+	"bytes"
+	"github.com/gdtrp/brainfuck/stack"
+	"strings"
+	"testing"
+)
+
+//ook script printing byte 2 and reading input into the next cell: "++.>,."
+const ookScript = "Ook. Ook. Ook. Ook.\nOok! Ook. Ook. Ook? Ook. Ook! Ook! Ook."
+
+//script printing 1 if cell can hold 256 and 0 otherwise
+const overflow = "++++++++++++++++[>++++++++++++++++<-]>[>+<[-]]>."
+
+func TestCompiler_Header(t *testing.T) {
+	c, _ := New()
+	for _, test := range []struct {
+		name     string
+		script   string
+		input    string
+		expected []byte
+	}{
+		{"no header", "+.", "", []byte{1}},
+		{"empty header", "#!bf\n+.", "", []byte{1}},
+		{"header only", "#!bf cells=8", "", nil},
+		{"not a header", "#!bfx+.", "", []byte{1}},
+		{"short script", "#!", "", nil},
+		{"byte cells", "#!bf cells=8\n" + overflow, "", []byte{0}},
+		{"wide cells", "#!bf cells=16\n" + overflow, "", []byte{1}},
+		{"eof unchanged", "#!bf eof=unchanged\n+,.", "", []byte{1}},
+		{"eof zero", "#!bf eof=0\n+,.", "", []byte{0}},
+		{"eof minus one", "#!bf eof=-1 cells=32\n,.+[-].", "", []byte{255, 0}},
+		{"grow", "#!bf tape=1 tape=grow\n>>>+.", "", []byte{1}},
+		{"ook", "#!bf dialect=ook\n" + ookScript, "a", []byte{2, 'a'}},
+	} {
+		var output bytes.Buffer
+		if err := c.Compile(strings.NewReader(test.script), strings.NewReader(test.input), &output); err != nil {
+			t.Fatalf("%v: unexpected error %v", test.name, err)
+		}
+		if !bytes.Equal(output.Bytes(), test.expected) {
+			t.Fatalf("%v: expected %v but was %v", test.name, test.expected, output.Bytes())
+		}
+	}
+}
+
+func TestSplitHeader(t *testing.T) {
+	for _, test := range []struct {
+		script string
+		header string
+		rest   string
+		line   int
+		offset int
+	}{
+		{"+.", "", "+.", 1, 0},
+		{"#!bf cells=8\n+.", "#!bf cells=8\n", "+.", 2, 13},
+		{"#!bf", "#!bf", "", 1, 4},
+		{"#!bfx+.", "", "#!bfx+.", 1, 0},
+	} {
+		header, rest, start, err := SplitHeader(strings.NewReader(test.script))
+		if err != nil {
+			t.Fatalf("%q: unexpected error %v", test.script, err)
+		}
+		var buf bytes.Buffer
+		if _, err := buf.ReadFrom(rest); err != nil {
+			t.Fatalf("%q: unexpected error %v", test.script, err)
+		}
+		if header != test.header || buf.String() != test.rest || start.Line != test.line || start.Offset != test.offset {
+			t.Fatalf("%q: unexpected split %q %q %+v", test.script, header, buf.String(), start)
+		}
+	}
+}
+
+func TestCompiler_HeaderContext(t *testing.T) {
+	c, _ := New()
+	ctx := stack.NewContext(strings.NewReader(""), &bytes.Buffer{})
+	var positions []string
+	ctx.BeforeAction = func(ctx *stack.Context) error {
+		positions = append(positions, ctx.Stack.Current().Position().String())
+		return nil
+	}
+	script := "#!bf cells=16 tape=10 dialect=ook\n" + ookScript
+	if err := c.Run(strings.NewReader(script), ctx); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if ctx.CellWidth != 2 || len(ctx.Memory) != 20 {
+		t.Fatalf("wrong memory configuration: width %v, %v bytes", ctx.CellWidth, len(ctx.Memory))
+	}
+	expected := "2:1 2:11 3:1 3:11 3:21 3:31"
+	if strings.Join(positions, " ") != expected {
+		t.Fatalf("wrong positions expected %v but was %v", expected, strings.Join(positions, " "))
+	}
+}
+
+func TestCompiler_HeaderErrors(t *testing.T) {
+	c, _ := New()
+	restricted, _ := New()
+	restricted.AllowPragmas(PragmaEOF, PragmaDialect)
+	for _, test := range []struct {
+		compiler Compiler
+		script   string
+		error    string
+	}{
+		{c, "#!bf cells=12", `1:6: wrong value of pragma cells: "12"`},
+		{c, "#!bf  eof=1\n+", `1:7: wrong value of pragma eof: "1"`},
+		{c, "#!bf tape=0", `1:6: wrong value of pragma tape: "0"`},
+		{c, "#!bf dialect=ook x", `1:18: wrong pragma "x"`},
+		{c, "#!bf colour=red", "1:6: unknown pragma colour"},
+		{c, "#!bf tape=1000000000000000", "1:6: memory size is limited to 67108864 bytes"},
+		{c, "#!bf tape=99999999999999999999", `1:6: wrong value of pragma tape: "99999999999999999999"`},
+		{c, "#!bf tape=20000000 cells=32", "1:20: memory size is limited to 67108864 bytes"},
+		{restricted, "#!bf eof=0 cells=16", "1:12: pragma cells is not allowed"},
+	} {
+		err := test.compiler.Compile(strings.NewReader(test.script), nil, &bytes.Buffer{})
+		if _, ok := err.(*SyntaxError); !ok || err.Error() != test.error {
+			t.Fatalf("%q: error %q expected but was %v", test.script, test.error, err)
+		}
+	}
+	var output bytes.Buffer
+	if err := restricted.Compile(strings.NewReader("#!bf eof=0 dialect=ook\n"+ookScript), strings.NewReader(""), &output); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !bytes.Equal(output.Bytes(), []byte{2, 0}) {
+		t.Fatalf("wrong output %v", output.Bytes())
+	}
+}
+
+func TestCompiler_HeaderMinify(t *testing.T) {
+	c, _ := New()
+	for _, test := range []struct {
+		script   string
+		expected string
+	}{
+		{"#!bf cells=16\n+ comment -.", "#!bf cells=16\n."},
+		{"#!bf dialect=ook eof=0\n" + ookScript, "#!bf eof=0\n++.>,."},
+		{"#!bf dialect=ook\nOok. Ook! Ook! Ook!", ",-"},
+	} {
+		var output bytes.Buffer
+		if _, err := c.Minify(strings.NewReader(test.script), &output); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if output.String() != test.expected {
+			t.Fatalf("%q: expected %q but was %q", test.script, test.expected, output.String())
+		}
+	}
+}
+
+func TestCompiler_HeaderResume(t *testing.T) {
+	c, _ := New()
+	script := "#!bf dialect=ook eof=0\n" + ookScript
+	result, snapshots := runWithSnapshots(t, c, script, []byte("x"), 1)
+	if snapshots == 0 || !bytes.Equal(result, []byte{2, 'x'}) {
+		t.Fatalf("wrong output %v after %v snapshots", result, snapshots)
+	}
+}
+
+func TestCompiler_HeaderLint(t *testing.T) {
+	c, _ := New()
+	diagnostics, err := c.Lint(strings.NewReader("#!bf eof=-1 tape=grow\n+[-]"))
+	if err != nil || len(diagnostics) != 0 {
+		t.Fatalf("no diagnostics expected but was %v, error %v", diagnostics, err)
+	}
+}
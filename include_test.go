@@ -0,0 +1,105 @@
+package compiler
+
+import (
+	"bytes"
+	"github.com/gdtrp/brainfuck/stack"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCompiler_Include(t *testing.T) {
+	c, _ := New()
+	c.SetIncludePath(fstest.MapFS{
+		"lib/inc.bf":   {Data: []byte("#define INC(n) {+}*n\n+\n#include \"lib/out.bf\"\n")},
+		"lib/out.bf":   {Data: []byte("\n.")},
+		"std/cells.bf": {Data: []byte("#define ADD(n) -")},
+	})
+	script := "+\n#include \"lib/inc.bf\"\n$INC(2).\n#include \"std/cells.bf\"\n$ADD(1)."
+	var positions []string
+	var output bytes.Buffer
+	ctx := stack.NewContext(nil, &output)
+	ctx.BeforeAction = func(ctx *stack.Context) error {
+		positions = append(positions, ctx.Stack.Current().Position().String())
+		return nil
+	}
+	if err := c.Run(c.Preprocess(strings.NewReader(script)), ctx); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !bytes.Equal(output.Bytes(), []byte{2, 4, 3}) {
+		t.Fatalf("wrong output %v", output.Bytes())
+	}
+	expected := "1:1 lib/inc.bf:2:1 lib/out.bf:2:1 3:1 3:2 3:3 5:1 5:2"
+	if strings.Join(positions, " ") != expected {
+		t.Fatalf("wrong positions expected %v but was %v", expected, strings.Join(positions, " "))
+	}
+}
+
+func TestCompiler_IncludeStdlib(t *testing.T) {
+	c, _ := New()
+	script := `#include "std/print.bf"
+#include "std/math.bf"
+#include "std/cells.bf"
+#include "std/cells.bf"
+$ADD(12)>$ADD(11)<$MULTIPLY $PRINT_DECIMAL $COPY>$PRINT_DIGIT`
+	var output bytes.Buffer
+	if err := c.Compile(c.Preprocess(strings.NewReader(script)), nil, &output); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if output.String() != "132\xb4" {
+		t.Fatalf("wrong output %q", output.String())
+	}
+}
+
+func TestCompiler_IncludeErrors(t *testing.T) {
+	c, _ := New()
+	c.SetIncludePath(fstest.MapFS{
+		"a.bf":     {Data: []byte("+\n#include \"b.bf\"")},
+		"b.bf":     {Data: []byte("#include \"a.bf\"")},
+		"open.bf":  {Data: []byte("\n#ifdef X")},
+		"group.bf": {Data: []byte("{+")},
+	})
+	for _, test := range []struct {
+		script string
+		error  string
+	}{
+		{"#include \"a.bf\"", "b.bf:1:1: include cycle: a.bf -> b.bf -> a.bf"},
+		{"\n#include \"missing.bf\"", "2:1: included file missing.bf is not found"},
+		{"#include missing.bf", "1:1: #include requires a quoted file path"},
+		{"#include \"open.bf\"\n#endif", "open.bf:2:1: missing #endif"},
+		{"#include \"group.bf\"", "group.bf:1:1: missing closing brace"},
+		{"{\n#include \"a.bf\"\n}*2", "2:1: #include inside of repetition group"},
+	} {
+		_, err := preprocess(c, test.script)
+		if _, ok := err.(*PreprocessError); !ok || err.Error() != test.error {
+			t.Fatalf("%q: error %q expected but was %v", test.script, test.error, err)
+		}
+	}
+}
+
+func TestWrapScript(t *testing.T) {
+	c, _ := New()
+	c.SetIncludePath(fstest.MapFS{"lib.bf": {Data: []byte("+\n+")}})
+	script := c.Preprocess(strings.NewReader("#include \"lib.bf\"\n."))
+	var source bytes.Buffer
+	var positions []string
+	ctx := stack.NewContext(nil, ioutil.Discard)
+	ctx.BeforeAction = func(ctx *stack.Context) error {
+		positions = append(positions, ctx.Stack.Current().Position().String())
+		return nil
+	}
+	if err := c.Run(WrapScript(script, io.TeeReader(script, &source)), ctx); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if expected := "lib.bf:1:1 lib.bf:2:1 2:1"; strings.Join(positions, " ") != expected {
+		t.Fatalf("wrong positions expected %v but was %v", expected, strings.Join(positions, " "))
+	}
+	if source.String() != "+\n+\n." {
+		t.Fatalf("wrong source %q", source.String())
+	}
+	if _, ok := WrapScript(strings.NewReader(""), &source).(*bytes.Buffer); !ok {
+		t.Fatalf("reader of untransformed script should be returned as is")
+	}
+}
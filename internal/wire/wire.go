@@ -0,0 +1,69 @@
+/*
+Package wire implements message framing with Content-Length headers used by language server
+and debug adapter protocols.
+*/
+package wire
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+//MaxMessageSize limits message body length so that a broken header does not allocate unlimited memory
+const MaxMessageSize = 16 << 20
+
+//ReadMessage reads single message body framed with Content-Length header
+func ReadMessage(reader *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && line == "" && length < 0 {
+				return nil, io.EOF
+			}
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		idx := strings.IndexByte(line, ':')
+		if idx < 0 {
+			return nil, fmt.Errorf("malformed header %q", line)
+		}
+		if strings.EqualFold(strings.TrimSpace(line[:idx]), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(line[idx+1:])); err != nil || length < 0 {
+				return nil, fmt.Errorf("malformed content length %q", line)
+			}
+			if length > MaxMessageSize {
+				return nil, fmt.Errorf("content length %d exceeds %d bytes", length, MaxMessageSize)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("missing content length header")
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+//WriteMessage writes value as json message framed with Content-Length header
+func WriteMessage(writer io.Writer, value interface{}) error {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(writer, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = writer.Write(body)
+	return err
+}
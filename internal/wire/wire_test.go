@@ -0,0 +1,36 @@
+package wire
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWriteAndReadMessage(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteMessage(&buf, map[string]int{"seq": 1}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if buf.String() != "Content-Length: 9\r\n\r\n{\"seq\":1}" {
+		t.Fatalf("wrong message %q", buf.String())
+	}
+	reader := bufio.NewReader(&buf)
+	body, err := ReadMessage(reader)
+	if err != nil || string(body) != `{"seq":1}` {
+		t.Fatalf("wrong body %q, error %v", body, err)
+	}
+	if _, err := ReadMessage(reader); err != io.EOF {
+		t.Fatalf("EOF expected but was %v", err)
+	}
+}
+
+func TestReadMessage_Malformed(t *testing.T) {
+	for _, message := range []string{"Content-Type: json\r\n\r\n{}", "Content-Length: x\r\n\r\n", "broken\r\n\r\n", "Content-Length: 5\r\n\r\n{}",
+		"Content-Length: -1\r\n\r\n", "Content-Length: 99999999999\r\n\r\n{}"} {
+		if _, err := ReadMessage(bufio.NewReader(strings.NewReader(message))); err == nil || err == io.EOF {
+			t.Errorf("error expected for %q but was %v", message, err)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package compiler
+
+import (
+	"github.com/gdtrp/brainfuck/stack"
+	"io"
+	"sort"
+	"strings"
+)
+
+//LintRule name of the check performed by linter
+type LintRule string
+
+const (
+	//brackets without a pair
+	LintUnbalanced LintRule = "unbalanced"
+	//loop with zero pointer movement which never changes the current cell
+	LintInfiniteLoop LintRule = "infiniteloop"
+	//loop right after closed loop which can never run
+	LintDeadLoop LintRule = "deadloop"
+	//command character which is likely a part of comment text
+	LintProse LintRule = "prose"
+)
+
+//LintRules contains all supported linter rules
+var LintRules = []LintRule{LintUnbalanced, LintInfiniteLoop, LintDeadLoop, LintProse}
+
+//inline marker prefix. "bflint:ignore rule" suppresses rules on the same line,
+//"bflint:disable rule" and "bflint:enable rule" suppress rules for the lines in between.
+//all rules are affected if no rule is listed
+const lintMarker = "bflint:"
+
+//Diagnostic describes single problem found by linter
+type Diagnostic struct {
+	stack.Position
+	Rule    LintRule `json:"rule"`
+	Message string   `json:"message"`
+}
+
+//loop which is being analysed
+type lintLoop struct {
+	start stack.Position
+	//net pointer movement inside the loop body
+	offset int
+	//current cell is changed or can't be analysed
+	changed bool
+}
+
+type linter struct {
+	diagnostics []Diagnostic
+	loops       []*lintLoop
+	//previous command token
+	previous byte
+	//previous byte of the script
+	last byte
+	//line text collected for inline markers
+	line          []byte
+	suppressed    map[int]map[LintRule]bool
+	regions       []lintRegion
+	openedRegions map[LintRule]int
+}
+
+//command placed right after a letter. it is reported if the next byte confirms that it is a part of text
+type proseCandidate struct {
+	diagnostic Diagnostic
+	//whether command must be followed by a letter ("well-known") or by a space ("end of sentence.")
+	letterAfter bool
+}
+
+func newProseCandidate(token byte, position stack.Position) *proseCandidate {
+	switch token {
+	case '.', ',':
+		return &proseCandidate{diagnostic: Diagnostic{Position: position, Rule: LintProse,
+			Message: "command '" + string(token) + "' after word is likely a part of comment"}}
+	case '-':
+		return &proseCandidate{letterAfter: true, diagnostic: Diagnostic{Position: position, Rule: LintProse,
+			Message: "command '-' between letters is likely a part of comment"}}
+	}
+	return nil
+}
+
+//disabled range of lines
+type lintRegion struct {
+	rule     LintRule
+	from, to int
+}
+
+/*
+lint provided script and return found problems ordered by position. rules provided in disabled argument are not checked
+*/
+func (c Compiler) Lint(script io.Reader, disabled ...LintRule) ([]Diagnostic, error) {
+	l := &linter{
+		suppressed:    make(map[int]map[LintRule]bool),
+		openedRegions: make(map[LintRule]int),
+	}
+	h, body, start, err := c.readHeader(script)
+	if err != nil {
+		return nil, err
+	}
+	s := newScanner(body)
+	s.position = start
+	if h != nil {
+		l.endLine(1)
+	}
+	var checker *strictChecker
+	if c.strict {
+		//strict mode comments are linted as text but their bytes are not commands
+		checker = &strictChecker{compiler: c}
+	}
+	var pending *proseCandidate
+	for {
+		token, position, err := s.next()
+		if err == io.EOF {
+			if pending != nil && !pending.letterAfter {
+				l.report(pending.diagnostic)
+			}
+			l.endLine(position.Line)
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if pending != nil {
+			if pending.letterAfter && isLetter(token) || !pending.letterAfter && isSpace(token) {
+				l.report(pending.diagnostic)
+			}
+			pending = nil
+		}
+		if token == '\n' {
+			l.endLine(position.Line)
+		} else {
+			l.line = append(l.line, token)
+		}
+		comment := false
+		if checker != nil {
+			//unknown characters are reported by compilation, checking continues after them
+			if skip, err := checker.check(token, position); err != nil {
+				checker.marker = nil
+			} else {
+				comment = skip
+			}
+		}
+		if !comment && c.IsCommand(stack.Command(token)) {
+			if isLetter(l.last) {
+				pending = newProseCandidate(token, position)
+			}
+			l.command(token, position)
+		}
+		l.last = token
+	}
+	for _, loop := range l.loops {
+		l.report(Diagnostic{Position: loop.start, Rule: LintUnbalanced, Message: "missing closing brackets"})
+	}
+	return l.filter(disabled), nil
+}
+
+//analyse command token
+func (l *linter) command(token byte, position stack.Position) {
+	var current *lintLoop
+	if len(l.loops) > 0 {
+		current = l.loops[len(l.loops)-1]
+	}
+	switch token {
+	case '[':
+		if l.previous == ']' {
+			l.report(Diagnostic{Position: position, Rule: LintDeadLoop, Message: "loop right after closed loop never runs"})
+		}
+		if current != nil {
+			current.changed = true
+		}
+		l.loops = append(l.loops, &lintLoop{start: position})
+	case ']':
+		if current == nil {
+			l.report(Diagnostic{Position: position, Rule: LintUnbalanced, Message: "missing start loop"})
+			break
+		}
+		if current.offset == 0 && !current.changed {
+			l.report(Diagnostic{Position: current.start, Rule: LintInfiniteLoop,
+				Message: "loop never changes current cell and never ends once entered"})
+		}
+		l.loops = l.loops[:len(l.loops)-1]
+	case '>', '<':
+		if current != nil {
+			if token == '>' {
+				current.offset++
+			} else {
+				current.offset--
+			}
+		}
+	case '.':
+	case '+', '-', ',':
+		if current != nil && current.offset == 0 {
+			current.changed = true
+		}
+	default:
+		//custom operation can change anything
+		if current != nil {
+			current.changed = true
+		}
+	}
+	l.previous = token
+}
+
+func (l *linter) report(diagnostic Diagnostic) {
+	l.diagnostics = append(l.diagnostics, diagnostic)
+}
+
+//parse inline markers of finished line
+func (l *linter) endLine(line int) {
+	text := string(l.line)
+	l.line = l.line[:0]
+	for {
+		idx := strings.Index(text, lintMarker)
+		if idx < 0 {
+			return
+		}
+		text = text[idx+len(lintMarker):]
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			return
+		}
+		var rules []LintRule
+		for _, field := range fields[1:] {
+			if !IsLintRule(LintRule(field)) {
+				break
+			}
+			rules = append(rules, LintRule(field))
+		}
+		if len(rules) == 0 {
+			rules = LintRules
+		}
+		for _, rule := range rules {
+			switch fields[0] {
+			case "ignore":
+				if l.suppressed[line] == nil {
+					l.suppressed[line] = make(map[LintRule]bool)
+				}
+				l.suppressed[line][rule] = true
+			case "disable":
+				if _, found := l.openedRegions[rule]; !found {
+					l.openedRegions[rule] = line
+				}
+			case "enable":
+				if from, found := l.openedRegions[rule]; found {
+					l.regions = append(l.regions, lintRegion{rule: rule, from: from, to: line})
+					delete(l.openedRegions, rule)
+				}
+			}
+		}
+	}
+}
+
+//remove disabled and suppressed diagnostics and sort result by position
+func (l *linter) filter(disabled []LintRule) []Diagnostic {
+	for rule, from := range l.openedRegions {
+		l.regions = append(l.regions, lintRegion{rule: rule, from: from, to: int(^uint(0) >> 1)})
+	}
+	result := make([]Diagnostic, 0, len(l.diagnostics))
+	for _, d := range l.diagnostics {
+		if l.isSuppressed(d, disabled) {
+			continue
+		}
+		result = append(result, d)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Offset < result[j].Offset
+	})
+	return result
+}
+
+func (l *linter) isSuppressed(d Diagnostic, disabled []LintRule) bool {
+	for _, rule := range disabled {
+		if rule == d.Rule {
+			return true
+		}
+	}
+	if l.suppressed[d.Line][d.Rule] {
+		return true
+	}
+	for _, region := range l.regions {
+		if region.rule == d.Rule && d.Line >= region.from && d.Line <= region.to {
+			return true
+		}
+	}
+	return false
+}
+
+//returns true if the rule is one of LintRules
+func IsLintRule(rule LintRule) bool {
+	for _, r := range LintRules {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+func isLetter(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
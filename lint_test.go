@@ -0,0 +1,77 @@
+package compiler
+
+import (
+	"bytes"
+	"github.com/gdtrp/brainfuck/stack"
+	"testing"
+)
+
+var lintScripts = []struct {
+	name     string
+	script   string
+	disabled []LintRule
+	result   []Diagnostic
+}{
+	{"valid script", "++[>+<-]>.", nil, nil},
+	{"missing start loop", "+]", nil, []Diagnostic{
+		{Position: pos(1, 1, 2), Rule: LintUnbalanced, Message: "missing start loop"}}},
+	{"missing closing brackets", "+\n[[-]", nil, []Diagnostic{
+		{Position: pos(2, 2, 1), Rule: LintUnbalanced, Message: "missing closing brackets"}}},
+	{"infinite loop", "+[>+<]", nil, []Diagnostic{
+		{Position: pos(1, 1, 2), Rule: LintInfiniteLoop, Message: "loop never changes current cell and never ends once entered"}}},
+	{"moving loop is not infinite", "+[>]", nil, nil},
+	{"custom operation is not analysed", "+[*]", nil, nil},
+	{"dead loop", "+[-] [-]", nil, []Diagnostic{
+		{Position: pos(5, 1, 6), Rule: LintDeadLoop, Message: "loop right after closed loop never runs"}}},
+	{"prose", "print result. well-known, hello world", nil, []Diagnostic{
+		{Position: pos(12, 1, 13), Rule: LintProse, Message: "command '.' after word is likely a part of comment"},
+		{Position: pos(18, 1, 19), Rule: LintProse, Message: "command '-' between letters is likely a part of comment"},
+		{Position: pos(24, 1, 25), Rule: LintProse, Message: "command ',' after word is likely a part of comment"}}},
+	{"commands after word are not prose", "a+ b.+", nil, nil},
+	{"disabled rule", "+[-][-]]", []LintRule{LintDeadLoop}, []Diagnostic{
+		{Position: pos(7, 1, 8), Rule: LintUnbalanced, Message: "missing start loop"}}},
+	{"ignore marker", "+[-][-] bflint:ignore deadloop\n+[-][-]", nil, []Diagnostic{
+		{Position: pos(35, 2, 5), Rule: LintDeadLoop, Message: "loop right after closed loop never runs"}}},
+	{"ignore marker without rules", "end. bflint:ignore", nil, nil},
+	{"disable marker", "bflint:disable deadloop\n[-][-]\nbflint:enable deadloop\n+[-][-]", nil, []Diagnostic{
+		{Position: pos(58, 4, 5), Rule: LintDeadLoop, Message: "loop right after closed loop never runs"}}},
+}
+
+func pos(offset int, line int, column int) stack.Position {
+	return stack.Position{Offset: offset, Line: line, Column: column}
+}
+
+func TestCompiler_Lint(t *testing.T) {
+	compiler, error := New(CustomOperation{command: "*"})
+	if error != nil {
+		t.Fatalf("error should be nil")
+	}
+	for _, test := range lintScripts {
+		t.Run(test.name, func(t *testing.T) {
+			result, error := compiler.Lint(bytes.NewBufferString(test.script), test.disabled...)
+			if error != nil {
+				t.Fatalf("unexpected error %v", error)
+			}
+			if len(result) != len(test.result) {
+				t.Fatalf("wrong result expected %v but was %v", test.result, result)
+			}
+			for i, d := range test.result {
+				if result[i] != d {
+					t.Fatalf("wrong result expected %v but was %v", test.result, result)
+				}
+			}
+		})
+	}
+}
+
+func TestCompiler_LintStrict(t *testing.T) {
+	c, _ := New()
+	c.SetStrict(true, "#")
+	result, err := c.Lint(bytes.NewBufferString("+[-] # loop [\n+ x ."))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(result) != 0 {
+		t.Fatalf("bracket in comment should not be linted but was %v", result)
+	}
+}
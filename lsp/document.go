@@ -0,0 +1,151 @@
+package lsp
+
+import (
+	compiler "github.com/gdtrp/brainfuck"
+	"github.com/gdtrp/brainfuck/stack"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+//document is an opened script text with helpers to convert between byte offsets and protocol positions
+type document struct {
+	text string
+	//byte offsets of line beginnings
+	lines []int
+	//brackets read by the compiler scanner by their offsets. header, comments and other tokens are skipped
+	brackets map[int]stack.Command
+	//offsets of the brackets in script order
+	order []int
+}
+
+func newDocument(c compiler.Compiler, text string) *document {
+	lines := []int{0}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	d := &document{text: text, lines: lines, brackets: make(map[int]stack.Command)}
+	//tokens read before syntax error are still matched
+	tokens, _ := c.Tokens(strings.NewReader(text))
+	for _, t := range tokens {
+		if t.Command == "[" || t.Command == "]" {
+			d.brackets[t.Offset] = t.Command
+			d.order = append(d.order, t.Offset)
+		}
+	}
+	return d
+}
+
+//convert protocol position (utf-16 based character) to byte offset
+func (d *document) offset(p position) int {
+	if p.Line < 0 {
+		return 0
+	}
+	if p.Line >= len(d.lines) {
+		return len(d.text)
+	}
+	offset := d.lines[p.Line]
+	for units := 0; offset < len(d.text) && d.text[offset] != '\n' && units < p.Character; {
+		r, size := utf8.DecodeRuneInString(d.text[offset:])
+		units += utf16Length(r)
+		offset += size
+	}
+	return offset
+}
+
+//convert byte offset to protocol position
+func (d *document) position(offset int) position {
+	if offset > len(d.text) {
+		offset = len(d.text)
+	}
+	line := sort.Search(len(d.lines), func(i int) bool {
+		return d.lines[i] > offset
+	}) - 1
+	character := 0
+	for _, r := range d.text[d.lines[line]:offset] {
+		character += utf16Length(r)
+	}
+	return position{Line: line, Character: character}
+}
+
+//range covering single byte at offset
+func (d *document) byteRange(offset int) textRange {
+	return textRange{Start: d.position(offset), End: d.position(offset + 1)}
+}
+
+//byte offset of the script position. position outside of the document is moved to the nearest line end
+func (d *document) at(p stack.Position) int {
+	if p.Line < 1 {
+		return 0
+	}
+	if p.Line > len(d.lines) {
+		return len(d.text)
+	}
+	offset := d.lines[p.Line-1] + p.Column - 1
+	end := len(d.text)
+	if p.Line < len(d.lines) {
+		end = d.lines[p.Line] - 1
+	}
+	if offset > end {
+		return end
+	}
+	return offset
+}
+
+//number of loops enclosing the byte at offset. bracket belongs to the loop it opens or closes
+func (d *document) depth(offset int) int {
+	depth := 0
+	for _, i := range d.order {
+		if i > offset || i == offset && d.brackets[i] == "]" {
+			break
+		}
+		switch d.brackets[i] {
+		case "[":
+			depth++
+		case "]":
+			if depth > 0 {
+				depth--
+			}
+		}
+	}
+	return depth
+}
+
+//offset of the bracket matching the one at offset. returns false if there is no bracket or it is unbalanced
+func (d *document) match(offset int) (int, bool) {
+	if _, found := d.brackets[offset]; !found {
+		return 0, false
+	}
+	var opened []int
+	for _, i := range d.order {
+		switch d.brackets[i] {
+		case "[":
+			opened = append(opened, i)
+		case "]":
+			if len(opened) == 0 {
+				if i == offset {
+					return 0, false
+				}
+				continue
+			}
+			start := opened[len(opened)-1]
+			opened = opened[:len(opened)-1]
+			if start == offset {
+				return i, true
+			}
+			if i == offset {
+				return start, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func utf16Length(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
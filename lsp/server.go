@@ -0,0 +1,420 @@
+/*
+Package lsp implements language server protocol for brainfuck scripts.
+
+Server provides diagnostics for unbalanced brackets and other linter findings, highlights matching brackets,
+shows loop nesting depth on hover and formats documents. Scripts are checked by the same preprocessor and strict mode
+scanner as compiled scripts, errors of them are reported as diagnostics. Custom operations are recognized as commands
+if they are passed to NewServer or declared by the client in "commands" initialization option.
+*/
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	compiler "github.com/gdtrp/brainfuck"
+	"github.com/gdtrp/brainfuck/internal/wire"
+	"github.com/gdtrp/brainfuck/stack"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+//Server is a language server communicating over a single stream
+type Server struct {
+	operations []stack.ExternalOperation
+	compiler   compiler.Compiler
+	documents  map[string]*document
+	writer     io.Writer
+	shutdown   bool
+	//settings applied to the compiler
+	strict     bool
+	comments   []string
+	preprocess bool
+	includes   []fs.FS
+}
+
+type handler func(s *Server, params json.RawMessage) (interface{}, error)
+
+var requests = map[string]handler{
+	"initialize":                     (*Server).initialize,
+	"shutdown":                       (*Server).shutdownRequest,
+	"textDocument/hover":             (*Server).hover,
+	"textDocument/formatting":        (*Server).formatting,
+	"textDocument/documentHighlight": (*Server).highlight,
+	"textDocument/definition":        (*Server).definition,
+}
+
+var notifications = map[string]handler{
+	"textDocument/didOpen":   (*Server).didOpen,
+	"textDocument/didChange": (*Server).didChange,
+	"textDocument/didClose":  (*Server).didClose,
+}
+
+/*
+create new server. provided custom operations are treated as commands, same as in compiler.New
+*/
+func NewServer(ops ...stack.ExternalOperation) (*Server, error) {
+	c, err := compiler.New(ops...)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{
+		operations: ops,
+		compiler:   c,
+		documents:  make(map[string]*document),
+	}, nil
+}
+
+/*
+declare commands of custom operations which are not available to the server.
+declared commands are treated as operations with unknown effect. command should be a single byte, same as
+tokens read by the compiler
+*/
+func (s *Server) Declare(commands ...stack.Command) error {
+	ops := s.operations
+	for _, command := range commands {
+		if len(command) != 1 {
+			return fmt.Errorf("command %q should be a single byte", command)
+		}
+		if s.compiler.IsCommand(command) {
+			continue
+		}
+		ops = append(ops, declaredOperation(command))
+	}
+	return s.configure(ops)
+}
+
+/*
+enable or disable strict mode of the scripts, see compiler.SetStrict. syntax errors are reported as diagnostics
+*/
+func (s *Server) SetStrict(enabled bool, comments ...string) error {
+	strict, previous := s.strict, s.comments
+	s.strict, s.comments = enabled, comments
+	if err := s.configure(s.operations); err != nil {
+		s.strict, s.comments = strict, previous
+		return err
+	}
+	return nil
+}
+
+/*
+enable or disable preprocessing of the scripts, see compiler.Preprocess. included files are searched in provided paths
+and in compiler.Stdlib. preprocessor errors are reported as diagnostics
+*/
+func (s *Server) SetPreprocess(enabled bool, includes ...fs.FS) {
+	s.preprocess = enabled
+	s.includes = includes
+	s.compiler.SetIncludePath(includes...)
+}
+
+//create compiler of provided operations with the server settings
+func (s *Server) configure(ops []stack.ExternalOperation) error {
+	c, err := compiler.New(ops...)
+	if err != nil {
+		return err
+	}
+	if err := c.SetStrict(s.strict, s.comments...); err != nil {
+		return err
+	}
+	c.SetIncludePath(s.includes...)
+	s.operations = ops
+	s.compiler = c
+	return nil
+}
+
+/*
+serve requests read from reader and write responses and notifications to writer until exit notification is received
+or reader is finished
+*/
+func (s *Server) Serve(reader io.Reader, writer io.Writer) error {
+	s.writer = writer
+	buffered := bufio.NewReader(reader)
+	for {
+		body, err := wire.ReadMessage(buffered)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			if err := s.respond(nil, nil, &responseError{Code: codeParseError, Message: err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+		if err := s.handle(&msg); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(msg *message) error {
+	if msg.ID == nil {
+		if h, found := notifications[msg.Method]; found {
+			_, err := h(s, msg.Params)
+			return err
+		}
+		//unsupported notifications are ignored
+		return nil
+	}
+	h, found := requests[msg.Method]
+	if !found {
+		return s.respond(msg.ID, nil, &responseError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method})
+	}
+	if s.shutdown {
+		return s.respond(msg.ID, nil, &responseError{Code: codeInvalidRequest, Message: "server is shut down"})
+	}
+	result, err := h(s, msg.Params)
+	if err != nil {
+		respErr, ok := err.(*responseError)
+		if !ok {
+			respErr = &responseError{Code: codeInternalError, Message: err.Error()}
+		}
+		return s.respond(msg.ID, nil, respErr)
+	}
+	return s.respond(msg.ID, result, nil)
+}
+
+func (s *Server) respond(id *json.RawMessage, result interface{}, respErr *responseError) error {
+	response := message{JSONRPC: "2.0", ID: id, Error: respErr}
+	if id == nil {
+		null := json.RawMessage("null")
+		response.ID = &null
+	}
+	if respErr == nil {
+		body, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		response.Result = body
+	}
+	return wire.WriteMessage(s.writer, response)
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return wire.WriteMessage(s.writer, message{JSONRPC: "2.0", Method: method, Params: body})
+}
+
+func decode(params json.RawMessage, value interface{}) error {
+	if err := json.Unmarshal(params, value); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) initialize(params json.RawMessage) (interface{}, error) {
+	var p initializeParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	if p.InitializationOptions != nil {
+		var commands []stack.Command
+		for _, r := range p.InitializationOptions.Commands {
+			commands = append(commands, stack.Command(string(r)))
+		}
+		if err := s.Declare(commands...); err != nil {
+			return nil, err
+		}
+	}
+	return initializeResult{
+		Capabilities: serverCapabilities{
+			TextDocumentSync:           textDocumentSyncFull,
+			HoverProvider:              true,
+			DocumentFormattingProvider: true,
+			DocumentHighlightProvider:  true,
+			DefinitionProvider:         true,
+		},
+		ServerInfo: serverInfo{Name: "bf"},
+	}, nil
+}
+
+func (s *Server) shutdownRequest(json.RawMessage) (interface{}, error) {
+	s.shutdown = true
+	return nil, nil
+}
+
+func (s *Server) didOpen(params json.RawMessage) (interface{}, error) {
+	var p didOpenParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	s.documents[p.TextDocument.URI] = newDocument(s.compiler, p.TextDocument.Text)
+	return nil, s.publishDiagnostics(p.TextDocument.URI)
+}
+
+func (s *Server) didChange(params json.RawMessage) (interface{}, error) {
+	var p didChangeParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	if len(p.ContentChanges) == 0 {
+		return nil, nil
+	}
+	//only full synchronization is supported so the last change contains the whole document
+	s.documents[p.TextDocument.URI] = newDocument(s.compiler, p.ContentChanges[len(p.ContentChanges)-1].Text)
+	return nil, s.publishDiagnostics(p.TextDocument.URI)
+}
+
+func (s *Server) didClose(params json.RawMessage) (interface{}, error) {
+	var p didCloseParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	delete(s.documents, p.TextDocument.URI)
+	return nil, s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         p.TextDocument.URI,
+		Diagnostics: []diagnostic{},
+	})
+}
+
+func (s *Server) publishDiagnostics(uri string) error {
+	doc := s.documents[uri]
+	diagnostics := []diagnostic{}
+	if err := s.check(doc.text); err != nil {
+		diagnostics = append(diagnostics, errorDiagnostic(doc, err))
+	}
+	found, err := s.compiler.Lint(strings.NewReader(doc.text))
+	if err != nil {
+		//script can't be linted, for example because of invalid header. the error is already reported by the check
+		if len(diagnostics) == 0 {
+			diagnostics = append(diagnostics, errorDiagnostic(doc, err))
+		}
+		found = nil
+	}
+	for _, d := range found {
+		severity := severityWarning
+		if d.Rule == compiler.LintUnbalanced {
+			severity = severityError
+		}
+		diagnostics = append(diagnostics, diagnostic{
+			Range:    doc.byteRange(d.Offset),
+			Severity: severity,
+			Code:     string(d.Rule),
+			Source:   "bf",
+			Message:  d.Message,
+		})
+	}
+	return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: diagnostics})
+}
+
+//read the script by the compiler the same way it is compiled, without execution. returns the first error
+func (s *Server) check(text string) error {
+	var script io.Reader = strings.NewReader(text)
+	if s.preprocess {
+		script = s.compiler.Preprocess(script)
+	}
+	_, err := s.compiler.Tokens(script)
+	return err
+}
+
+//diagnostic of the error which stops compilation. errors in included files are shown at the start of the document
+func errorDiagnostic(doc *document, err error) diagnostic {
+	var p stack.Position
+	message := err.Error()
+	switch e := err.(type) {
+	case *compiler.SyntaxError:
+		p, message = e.Position, e.Message
+	case *compiler.PreprocessError:
+		p, message = e.Position, e.Message
+	}
+	offset := 0
+	if p.File == "" {
+		offset = doc.at(p)
+	} else {
+		message = err.Error()
+	}
+	return diagnostic{Range: doc.byteRange(offset), Severity: severityError, Source: "bf", Message: message}
+}
+
+//find opened document and byte offset of requested position
+func (s *Server) locate(params json.RawMessage) (*document, string, int, error) {
+	var p textDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, "", 0, err
+	}
+	doc, found := s.documents[p.TextDocument.URI]
+	if !found {
+		return nil, "", 0, &responseError{Code: codeInvalidParams, Message: "document is not opened: " + p.TextDocument.URI}
+	}
+	return doc, p.TextDocument.URI, doc.offset(p.Position), nil
+}
+
+func (s *Server) hover(params json.RawMessage) (interface{}, error) {
+	doc, _, offset, err := s.locate(params)
+	if err != nil {
+		return nil, err
+	}
+	return hover{
+		Contents: markupContent{Kind: "markdown", Value: fmt.Sprintf("loop depth: %d", doc.depth(offset))},
+		Range:    doc.byteRange(offset),
+	}, nil
+}
+
+func (s *Server) highlight(params json.RawMessage) (interface{}, error) {
+	doc, _, offset, err := s.locate(params)
+	if err != nil {
+		return nil, err
+	}
+	pair, found := doc.match(offset)
+	if !found {
+		return []documentHighlight{}, nil
+	}
+	return []documentHighlight{{Range: doc.byteRange(offset)}, {Range: doc.byteRange(pair)}}, nil
+}
+
+func (s *Server) definition(params json.RawMessage) (interface{}, error) {
+	doc, uri, offset, err := s.locate(params)
+	if err != nil {
+		return nil, err
+	}
+	pair, found := doc.match(offset)
+	if !found {
+		return nil, nil
+	}
+	return location{URI: uri, Range: doc.byteRange(pair)}, nil
+}
+
+func (s *Server) formatting(params json.RawMessage) (interface{}, error) {
+	var p documentFormattingParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, found := s.documents[p.TextDocument.URI]
+	if !found {
+		return nil, &responseError{Code: codeInvalidParams, Message: "document is not opened: " + p.TextDocument.URI}
+	}
+	var buf bytes.Buffer
+	if err := s.compiler.Format(strings.NewReader(doc.text), &buf); err != nil {
+		return nil, err
+	}
+	if buf.String() == doc.text {
+		return []textEdit{}, nil
+	}
+	return []textEdit{{
+		Range:   textRange{Start: position{}, End: doc.position(len(doc.text))},
+		NewText: buf.String(),
+	}}, nil
+}
+
+//declaredOperation is a placeholder of custom operation known only by its command
+type declaredOperation stack.Command
+
+func (o declaredOperation) Command() stack.Command {
+	return stack.Command(o)
+}
+
+func (o declaredOperation) Action() func(*stack.Context) error {
+	return func(*stack.Context) error {
+		return fmt.Errorf("operation %v is declared without implementation", o)
+	}
+}
@@ -0,0 +1,302 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	compiler "github.com/gdtrp/brainfuck"
+	"github.com/gdtrp/brainfuck/internal/wire"
+	"io"
+	"testing"
+)
+
+//client drives the server through in-memory pipes
+type client struct {
+	t      *testing.T
+	writer *io.PipeWriter
+	reader *bufio.Reader
+	done   chan error
+	id     int
+}
+
+func newClient(t *testing.T, server *Server) *client {
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	c := &client{t: t, writer: inWriter, reader: bufio.NewReader(outReader), done: make(chan error, 1)}
+	go func() {
+		err := server.Serve(inReader, outWriter)
+		outWriter.Close()
+		c.done <- err
+	}()
+	return c
+}
+
+func (c *client) send(method string, id *int, params interface{}) {
+	body, err := json.Marshal(params)
+	if err != nil {
+		c.t.Fatalf("unexpected error %v", err)
+	}
+	msg := message{JSONRPC: "2.0", Method: method, Params: body}
+	if id != nil {
+		raw := json.RawMessage(string(mustMarshal(c.t, *id)))
+		msg.ID = &raw
+	}
+	if err := wire.WriteMessage(c.writer, msg); err != nil {
+		c.t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func (c *client) read() message {
+	body, err := wire.ReadMessage(c.reader)
+	if err != nil {
+		c.t.Fatalf("unexpected error %v", err)
+	}
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		c.t.Fatalf("unexpected error %v", err)
+	}
+	return msg
+}
+
+//send request and decode result of the response into value
+func (c *client) request(method string, params interface{}, value interface{}) *responseError {
+	c.id++
+	id := c.id
+	c.send(method, &id, params)
+	msg := c.read()
+	if msg.Error != nil {
+		return msg.Error
+	}
+	if err := json.Unmarshal(msg.Result, value); err != nil {
+		c.t.Fatalf("unexpected error %v", err)
+	}
+	return nil
+}
+
+func (c *client) diagnostics() publishDiagnosticsParams {
+	msg := c.read()
+	if msg.Method != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("diagnostics notification expected but was %v", msg.Method)
+	}
+	var p publishDiagnosticsParams
+	if err := json.Unmarshal(msg.Params, &p); err != nil {
+		c.t.Fatalf("unexpected error %v", err)
+	}
+	return p
+}
+
+func (c *client) exit() {
+	var result interface{}
+	c.request("shutdown", nil, &result)
+	c.send("exit", nil, nil)
+	if err := <-c.done; err != nil {
+		c.t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func mustMarshal(t *testing.T, value interface{}) []byte {
+	body, err := json.Marshal(value)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	return body
+}
+
+const uri = "file:///test.bf"
+
+func open(c *client, text string) publishDiagnosticsParams {
+	var result initializeResult
+	if err := c.request("initialize", initializeParams{InitializationOptions: &initializationOptions{Commands: "*"}}, &result); err != nil {
+		c.t.Fatalf("unexpected error %v", err)
+	}
+	if !result.Capabilities.HoverProvider || result.Capabilities.TextDocumentSync != textDocumentSyncFull {
+		c.t.Fatalf("wrong capabilities %v", result.Capabilities)
+	}
+	c.send("initialized", nil, struct{}{})
+	c.send("textDocument/didOpen", nil, didOpenParams{TextDocument: textDocumentItem{URI: uri, LanguageID: "bf", Text: text}})
+	return c.diagnostics()
+}
+
+func TestServer_Diagnostics(t *testing.T) {
+	server, _ := NewServer()
+	c := newClient(t, server)
+	p := open(c, "+[*]\n]")
+	if p.URI != uri || len(p.Diagnostics) != 1 {
+		t.Fatalf("single diagnostic expected but was %v", p.Diagnostics)
+	}
+	d := p.Diagnostics[0]
+	if d.Severity != severityError || d.Message != "missing start loop" || d.Range.Start != (position{Line: 1, Character: 0}) {
+		t.Fatalf("wrong diagnostic %v", d)
+	}
+	c.send("textDocument/didChange", nil, didChangeParams{
+		TextDocument:   textDocumentIdentifier{URI: uri},
+		ContentChanges: []contentChange{{Text: "+[-]"}},
+	})
+	if p := c.diagnostics(); len(p.Diagnostics) != 0 {
+		t.Fatalf("diagnostics should be empty but was %v", p.Diagnostics)
+	}
+	c.exit()
+}
+
+func TestServer_HeaderError(t *testing.T) {
+	server, _ := NewServer()
+	c := newClient(t, server)
+	p := open(c, "#!bf eof=2\n+")
+	if len(p.Diagnostics) != 1 {
+		t.Fatalf("single diagnostic expected but was %v", p.Diagnostics)
+	}
+	d := p.Diagnostics[0]
+	if d.Severity != severityError || d.Message != `wrong value of pragma eof: "2"` || d.Range.Start != (position{Line: 0, Character: 5}) {
+		t.Fatalf("wrong diagnostic %v", d)
+	}
+	c.exit()
+}
+
+func TestServer_UndeclaredCommand(t *testing.T) {
+	server, _ := NewServer()
+	c := newClient(t, server)
+	var result initializeResult
+	c.request("initialize", initializeParams{}, &result)
+	c.send("textDocument/didOpen", nil, didOpenParams{TextDocument: textDocumentItem{URI: uri, Text: "+[*]"}})
+	p := c.diagnostics()
+	if len(p.Diagnostics) != 1 || p.Diagnostics[0].Code != "infiniteloop" {
+		t.Fatalf("infinite loop diagnostic expected but was %v", p.Diagnostics)
+	}
+	c.exit()
+}
+
+func TestServer_Hover(t *testing.T) {
+	server, _ := NewServer()
+	c := newClient(t, server)
+	open(c, "+[\n>[-]<\n]")
+	var result hover
+	params := textDocumentPositionParams{TextDocument: textDocumentIdentifier{URI: uri}, Position: position{Line: 1, Character: 2}}
+	if err := c.request("textDocument/hover", params, &result); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if result.Contents.Value != "loop depth: 2" {
+		t.Fatalf("wrong hover value %v", result.Contents.Value)
+	}
+	c.exit()
+}
+
+func TestServer_Highlight(t *testing.T) {
+	server, _ := NewServer()
+	c := newClient(t, server)
+	open(c, "+[\n>[-]<\n]")
+	var result []documentHighlight
+	params := textDocumentPositionParams{TextDocument: textDocumentIdentifier{URI: uri}, Position: position{Line: 2, Character: 0}}
+	if err := c.request("textDocument/documentHighlight", params, &result); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(result) != 2 || result[1].Range.Start != (position{Line: 0, Character: 1}) {
+		t.Fatalf("wrong highlights %v", result)
+	}
+	var definition location
+	if err := c.request("textDocument/definition", params, &definition); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if definition.URI != uri || definition.Range.Start != (position{Line: 0, Character: 1}) {
+		t.Fatalf("wrong definition %v", definition)
+	}
+	c.exit()
+}
+
+func TestServer_Formatting(t *testing.T) {
+	server, _ := NewServer()
+	c := newClient(t, server)
+	open(c, "+[\n>[-]<\n]")
+	var result []textEdit
+	params := documentFormattingParams{TextDocument: textDocumentIdentifier{URI: uri}}
+	if err := c.request("textDocument/formatting", params, &result); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(result) != 1 || result[0].NewText != "+[\n\t>[-]<\n]" || result[0].Range.End != (position{Line: 2, Character: 1}) {
+		t.Fatalf("wrong edits %v", result)
+	}
+	c.exit()
+}
+
+func TestServer_UnknownMethod(t *testing.T) {
+	server, _ := NewServer()
+	c := newClient(t, server)
+	var result interface{}
+	if err := c.request("unknown", nil, &result); err == nil || err.Code != codeMethodNotFound {
+		t.Fatalf("method not found error expected but was %v", err)
+	}
+	c.exit()
+}
+
+func TestDocument_Position(t *testing.T) {
+	c, _ := compiler.New()
+	doc := newDocument(c, "a\n𝄞b\n")
+	if offset := doc.offset(position{Line: 1, Character: 2}); offset != 6 {
+		t.Fatalf("wrong offset expected 6 but was %v", offset)
+	}
+	if p := doc.position(6); p != (position{Line: 1, Character: 2}) {
+		t.Fatalf("wrong position %v", p)
+	}
+	if p := doc.position(8); p != (position{Line: 2, Character: 0}) {
+		t.Fatalf("wrong position %v", p)
+	}
+}
+
+func TestDocument_Header(t *testing.T) {
+	c, _ := compiler.New()
+	c.SetStrict(true, "#")
+	doc := newDocument(c, "#!bf cells=8\n[+] # [\n]")
+	if depth := doc.depth(21); depth != 0 {
+		t.Fatalf("bracket in comment should not close loop, depth was %v", depth)
+	}
+	if _, found := doc.match(19); found {
+		t.Fatalf("bracket in comment should not be matched")
+	}
+	if pair, found := doc.match(15); !found || pair != 13 {
+		t.Fatalf("wrong pair %v", pair)
+	}
+}
+
+func TestDocument_Ook(t *testing.T) {
+	c, _ := compiler.New()
+	//"[.]" with "[" at offset 17
+	doc := newDocument(c, "#!bf dialect=ook\nOok! Ook? Ook! Ook. Ook? Ook!")
+	if pair, found := doc.match(17); !found || pair != 37 {
+		t.Fatalf("wrong pair %v", pair)
+	}
+	if depth := doc.depth(27); depth != 1 {
+		t.Fatalf("wrong depth %v", depth)
+	}
+}
+
+func TestServer_CompileErrors(t *testing.T) {
+	server, _ := NewServer()
+	if err := server.SetStrict(true, "#"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	server.SetPreprocess(true)
+	c := newClient(t, server)
+	for _, test := range []struct {
+		script   string
+		message  string
+		position position
+	}{
+		{"+. # print it [\n+x", `unknown character 'x'`, position{Line: 1, Character: 1}},
+		{"#define A +\n$A}*2", "missing opening brace", position{Line: 1, Character: 2}},
+		{"#include \"missing.bf\"\n", "included file missing.bf is not found", position{Line: 0, Character: 0}},
+	} {
+		p := open(c, test.script)
+		if len(p.Diagnostics) != 1 {
+			t.Fatalf("%q: single diagnostic expected but was %v", test.script, p.Diagnostics)
+		}
+		if d := p.Diagnostics[0]; d.Severity != severityError || d.Message != test.message || d.Range.Start != test.position {
+			t.Fatalf("%q: wrong diagnostic %v", test.script, d)
+		}
+	}
+	c.exit()
+}
+
+func TestServer_DeclareMultiByte(t *testing.T) {
+	server, _ := NewServer()
+	if err := server.Declare("é"); err == nil {
+		t.Fatalf("single byte command error expected")
+	}
+}
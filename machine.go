@@ -0,0 +1,149 @@
+package compiler
+
+import (
+	"errors"
+	"github.com/gdtrp/brainfuck/stack"
+	"io"
+)
+
+//ErrInputNeeded is returned by Machine when the next operation reads input which is not provided yet
+var ErrInputNeeded = errors.New("input is needed")
+
+//ErrDone is returned by Machine.Step when the script is finished
+var ErrDone = errors.New("execution is done")
+
+/*
+Machine executes script step by step. script is read only when the stack has no operation ready for execution.
+input is provided by Input and CloseInput, reading operation pauses execution until input arrives
+*/
+type Machine struct {
+	compiler Compiler
+	script   io.Reader
+	//scanner is created by the first step after the script header is read
+	scanner *scanner
+	context *stack.Context
+	input   *machineInput
+	done    bool
+}
+
+//machineInput is input of machine. it returns io.EOF only after it is closed
+type machineInput struct {
+	buffer []byte
+	closed bool
+}
+
+func (i *machineInput) Read(p []byte) (int, error) {
+	if len(i.buffer) == 0 {
+		if i.closed {
+			return 0, io.EOF
+		}
+		return 0, ErrInputNeeded
+	}
+	n := copy(p, i.buffer)
+	i.buffer = i.buffer[n:]
+	return n, nil
+}
+
+/*
+create new machine for the script. outgoing bytes are written to writer, memory is configured by the compiler profile
+*/
+func NewMachine(c Compiler, script io.Reader, writer io.Writer) *Machine {
+	input := &machineInput{}
+	return &Machine{
+		compiler: c,
+		script:   script,
+		context:  c.NewContext(input, writer),
+		input:    input,
+	}
+}
+
+//append bytes to the input
+func (m *Machine) Input(data []byte) {
+	m.input.buffer = append(m.input.buffer, data...)
+}
+
+//close the input. reading operations do not pause anymore and leave the cell unchanged
+func (m *Machine) CloseInput() {
+	m.input.closed = true
+}
+
+/*
+execute exactly one operation. returns ErrInputNeeded without executing the operation if it reads input
+which is not provided yet. custom operation should read input before changing the context, it is executed again
+if reading returns ErrInputNeeded. returns ErrDone if the script is finished
+*/
+func (m *Machine) Step() error {
+	if m.done {
+		return ErrDone
+	}
+	if m.scanner == nil {
+		s, err := m.compiler.open(m.script, m.context)
+		if err != nil {
+			return err
+		}
+		m.scanner = s
+	}
+	for !m.context.HasNext() {
+		token, position, err := m.scanner.next()
+		if err == io.EOF {
+			m.done = true
+			if err := m.context.ValidateExecution(); err != nil {
+				return err
+			}
+			return ErrDone
+		} else if err != nil {
+			return err
+		}
+		if _, err := m.compiler.add(stack.Command(token), position, m.context); err != nil {
+			return err
+		}
+	}
+	next := m.context.Stack.Next()
+	if next.Operation().Command() == "," && len(m.input.buffer) == 0 && !m.input.closed {
+		return ErrInputNeeded
+	}
+	if err := m.context.Step(); !errors.Is(err, ErrInputNeeded) {
+		return err
+	}
+	//custom operation reading input is executed again after input arrives
+	m.context.Stack.Retry()
+	return ErrInputNeeded
+}
+
+/*
+execute operations until predicate returns true. predicate is checked before every operation.
+returns nil if the script is finished
+*/
+func (m *Machine) RunUntil(predicate func(*Machine) bool) error {
+	for !predicate(m) {
+		if err := m.Step(); err == ErrDone {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//returns true if the script is finished
+func (m *Machine) Done() bool {
+	return m.done
+}
+
+//returns element of the last executed operation. returns nil if nothing is executed yet
+func (m *Machine) Current() stack.OperationalElement {
+	return m.context.Stack.Current()
+}
+
+//returns position of the last executed operation in the script
+func (m *Machine) Position() stack.Position {
+	if current := m.Current(); current != nil {
+		return current.Position()
+	}
+	return stack.Position{}
+}
+
+//returns execution context with memory and pointer
+func (m *Machine) Context() *stack.Context {
+	return m.context
+}
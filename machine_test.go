@@ -0,0 +1,130 @@
+package compiler
+
+import (
+	"bytes"
+	"github.com/gdtrp/brainfuck/stack"
+	"strings"
+	"testing"
+)
+
+func TestMachine_Scripts(t *testing.T) {
+	c, _ := New()
+	for _, test := range scripts {
+		var output bytes.Buffer
+		m := NewMachine(c, strings.NewReader(test.script), &output)
+		m.Input(test.input)
+		m.CloseInput()
+		if err := m.RunUntil(func(*Machine) bool { return false }); err != nil {
+			t.Fatalf("%v: unexpected error %v", test.name, err)
+		}
+		if !m.Done() {
+			t.Fatalf("%v: machine should be done", test.name)
+		}
+		if !bytes.Equal(output.Bytes(), test.result) {
+			t.Fatalf("%v: expected %v but was %v", test.name, test.result, output.Bytes())
+		}
+	}
+}
+
+func TestMachine_Step(t *testing.T) {
+	c, _ := New()
+	m := NewMachine(c, strings.NewReader("+[-]\n>+"), &bytes.Buffer{})
+	var positions []string
+	for {
+		err := m.Step()
+		if err == ErrDone {
+			break
+		} else if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		positions = append(positions, m.Position().String())
+	}
+	expected := "1:1 1:2 1:3 1:4 1:2 2:1 2:2"
+	if strings.Join(positions, " ") != expected {
+		t.Fatalf("wrong executed operations expected %v but was %v", expected, strings.Join(positions, " "))
+	}
+	if m.Context().Memory[1] != 1 {
+		t.Fatalf("wrong memory %v", m.Context().Memory[:2])
+	}
+}
+
+func TestMachine_InputNeeded(t *testing.T) {
+	c, _ := New()
+	var output bytes.Buffer
+	m := NewMachine(c, strings.NewReader(",[.,]"), &output)
+	untilDone := func(*Machine) bool { return false }
+	if err := m.RunUntil(untilDone); err != ErrInputNeeded {
+		t.Fatalf("input needed error expected but was %v", err)
+	}
+	if m.Current() != nil {
+		t.Fatalf("nothing should be executed but was %v", m.Position())
+	}
+	m.Input([]byte("ab"))
+	if err := m.RunUntil(untilDone); err != ErrInputNeeded {
+		t.Fatalf("input needed error expected but was %v", err)
+	}
+	if output.String() != "ab" || m.Position().Offset != 2 {
+		t.Fatalf("wrong output %q at %v", output.String(), m.Position())
+	}
+	m.Input([]byte("c\x00"))
+	if err := m.RunUntil(untilDone); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if output.String() != "abc" {
+		t.Fatalf("wrong output %q", output.String())
+	}
+}
+
+func TestMachine_CustomOperationInput(t *testing.T) {
+	//adds input byte to the cell and increments it
+	c, _ := New(CustomOperation{command: "*", action: func(ctx *stack.Context) error {
+		b := make([]byte, 1)
+		if _, err := ctx.Reader.Read(b); err != nil {
+			return err
+		}
+		return ctx.SetCurrentCell(ctx.GetCurrentCell() + uint32(b[0]) + 1)
+	}})
+	var output bytes.Buffer
+	m := NewMachine(c, strings.NewReader("+*."), &output)
+	untilDone := func(*Machine) bool { return false }
+	if err := m.RunUntil(untilDone); err != ErrInputNeeded {
+		t.Fatalf("input needed error expected but was %v", err)
+	}
+	if m.Position().Column != 1 || m.Context().Memory[0] != 1 {
+		t.Fatalf("custom operation should be executed again but was at %v with %v", m.Position(), m.Context().Memory[0])
+	}
+	m.Input([]byte("A"))
+	if err := m.RunUntil(untilDone); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if output.String() != "C" {
+		t.Fatalf("wrong output %q", output.String())
+	}
+}
+
+func TestMachine_CustomOperationInputInBlock(t *testing.T) {
+	//stores input byte in the current cell
+	read := CustomOperation{command: "*", action: func(ctx *stack.Context) error {
+		b := make([]byte, 1)
+		if _, err := ctx.Reader.Read(b); err != nil {
+			return err
+		}
+		return ctx.SetCurrentCell(uint32(b[0]))
+	}}
+	c, _ := New(append(blocks, read)...)
+	var output bytes.Buffer
+	m := NewMachine(c, strings.NewReader("+(>*.(*.))"), &output)
+	untilDone := func(*Machine) bool { return false }
+	for _, input := range []string{"A", "B"} {
+		if err := m.RunUntil(untilDone); err != ErrInputNeeded {
+			t.Fatalf("input needed error expected but was %v", err)
+		}
+		m.Input([]byte(input))
+	}
+	if err := m.RunUntil(untilDone); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if output.String() != "AB" {
+		t.Fatalf("wrong output %q", output.String())
+	}
+}
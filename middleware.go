@@ -0,0 +1,37 @@
+package compiler
+
+import (
+	"fmt"
+	"github.com/gdtrp/brainfuck/stack"
+)
+
+//Action is executed by operation
+type Action func(*stack.Context) error
+
+//Middleware wraps action of operation. next is the wrapped action
+type Middleware func(next Action) Action
+
+/*
+wrap actions of provided commands with middleware. all commands are wrapped if none provided.
+middleware added later is executed first. compilers copied before the call are not changed
+*/
+func (c *Compiler) Use(middleware Middleware, commands ...stack.Command) error {
+	for _, command := range commands {
+		if _, found := c.commands[command]; !found {
+			return fmt.Errorf("unknown command %v", command)
+		}
+	}
+	selected := make(map[stack.Command]bool)
+	for _, command := range commands {
+		selected[command] = true
+	}
+	result := make(map[stack.Command]stack.ExternalOperation, len(c.commands))
+	for command, operation := range c.commands {
+		if len(commands) == 0 || selected[command] {
+			operation = stack.WithAction(operation, middleware(operation.Action()))
+		}
+		result[command] = operation
+	}
+	c.commands = result
+	return nil
+}
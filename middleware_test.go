@@ -0,0 +1,71 @@
+package compiler
+
+import (
+	"bytes"
+	"errors"
+	"github.com/gdtrp/brainfuck/stack"
+	"strings"
+	"testing"
+)
+
+func TestCompiler_Use(t *testing.T) {
+	c, _ := New()
+	original := c
+	var calls []string
+	logging := func(name string) Middleware {
+		return func(next Action) Action {
+			return func(ctx *stack.Context) error {
+				calls = append(calls, name)
+				return next(ctx)
+			}
+		}
+	}
+	if err := c.Use(logging("inner"), ",", "."); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := c.Use(logging("outer"), ","); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	var output bytes.Buffer
+	if err := c.Compile(strings.NewReader(",[->+<]>."), strings.NewReader("\x03"), &output); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !bytes.Equal(output.Bytes(), []byte{3}) {
+		t.Fatalf("wrong output %v", output.Bytes())
+	}
+	if strings.Join(calls, " ") != "outer inner inner" {
+		t.Fatalf("wrong middleware calls %v", calls)
+	}
+	calls = nil
+	if err := original.Compile(strings.NewReader(",."), strings.NewReader("\x03"), &output); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(calls) != 0 {
+		t.Fatalf("copied compiler should not be changed but was called %v", calls)
+	}
+}
+
+func TestCompiler_UseError(t *testing.T) {
+	c, _ := New()
+	if err := c.Use(func(next Action) Action { return next }, "x"); err == nil {
+		t.Fatalf("unknown command error expected")
+	}
+	limit := errors.New("cell limit")
+	err := c.Use(func(next Action) Action {
+		return func(ctx *stack.Context) error {
+			if err := next(ctx); err != nil {
+				return err
+			}
+			if ctx.GetCurrentByte() > 2 {
+				return limit
+			}
+			return nil
+		}
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := c.Compile(strings.NewReader("++[>+++<-]"), nil, &bytes.Buffer{}); err != limit {
+		t.Fatalf("cell limit error expected but was %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package compiler
+
+import (
+	"bufio"
+	"github.com/gdtrp/brainfuck/stack"
+	"io"
+)
+
+/*
+pairs of default commands which neutralize each other when placed one after another.
+pointer moves are not included, "<>" and "><" fail when the pointer crosses memory bounds
+*/
+var inverseCommands = map[byte]byte{
+	'+': '-',
+	'-': '+',
+}
+
+/*
+minify script and write the result to writer. returns the number of bytes saved.
+every byte which is not a registered command is removed, adjacent inverse pairs "+-" and "-+" are cancelled
+unless the compiler profile stops execution on cell overflow, and loops which can never run (at the very beginning
+of the script or right after another loop is closed) are dropped. script header is kept, script in another dialect
+is minified to commands and its dialect pragma is removed. the output behaves identically under the default operation set
+*/
+func (c Compiler) Minify(script io.Reader, writer io.Writer) (int, error) {
+	h, body, start, err := c.readHeader(script)
+	if err != nil {
+		return 0, err
+	}
+	reader := bufio.NewReader(body)
+	var result, dropped []byte
+	read, depth := start.Offset, 0
+	if h != nil {
+		result = []byte(h.withoutDialect())
+	}
+	first := len(result)
+	cancel := c.profile == nil || c.profile.Overflow == stack.OverflowWrap
+	for {
+		token, err := reader.ReadByte()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, err
+		}
+		read++
+		if !c.IsCommand(stack.Command(token)) {
+			continue
+		}
+		if depth > 0 {
+			//inside of a loop which is never executed
+			dropped = append(dropped, token)
+			switch token {
+			case '[':
+				depth++
+			case ']':
+				depth--
+			}
+			continue
+		}
+		last := len(result) - 1
+		switch {
+		case token == '[' && (last < first || result[last] == ']'):
+			//memory is zeroed before the first command and current cell is zero right after loop is finished
+			depth = 1
+			dropped = append(dropped[:0], token)
+		case cancel && last >= first && isInverse(result[last], token):
+			result = result[:last]
+		default:
+			result = append(result, token)
+		}
+	}
+	if depth > 0 {
+		//loop is not closed. keep it to preserve the missing bracket error
+		result = append(result, dropped...)
+	}
+	if _, err := writer.Write(result); err != nil {
+		return 0, err
+	}
+	return read - len(result), nil
+}
+
+func isInverse(previous byte, token byte) bool {
+	inverse, found := inverseCommands[previous]
+	return found && inverse == token
+}
@@ -0,0 +1,86 @@
+package compiler
+
+import (
+	"bytes"
+	"testing"
+)
+
+var minifyScripts = []struct {
+	name   string
+	script string
+	result string
+	saved  int
+}{
+	{"comments are removed", "+ add\n+ one more.", "++.", 14},
+	{"inverse pairs are cancelled", "+--+.", ".", 4},
+	{"nested inverse pairs are cancelled", "++--.", ".", 4},
+	{"pointer pairs are kept", "<>+-><.", "<>><.", 2},
+	{"loop at the beginning is removed", "[.,]+.", "+.", 4},
+	{"loop after closed loop is removed", "+[-][>.[-]]+.", "+[-]+.", 7},
+	{"loop after cancelled pair is removed", "+[-]+-[.]", "+[-]", 5},
+	{"loop after other command is kept", "+[-]+[-]", "+[-]+[-]", 0},
+	{"not closed dead loop is kept", "[[-]", "[[-]", 0},
+}
+
+func TestCompiler_Minify(t *testing.T) {
+	compiler, error := New()
+	if error != nil {
+		t.Fatalf("error should be nil")
+	}
+	for _, test := range minifyScripts {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			saved, error := compiler.Minify(bytes.NewBufferString(test.script), &buf)
+			if error != nil {
+				t.Fatalf("unexpected error %v", error)
+			}
+			if buf.String() != test.result {
+				t.Fatalf("wrong result expected %v but was %v", test.result, buf.String())
+			}
+			if saved != test.saved {
+				t.Fatalf("wrong saved bytes count expected %v but was %v", test.saved, saved)
+			}
+		})
+	}
+}
+
+func TestCompiler_MinifyEdges(t *testing.T) {
+	portable, _ := LookupProfile(ProfilePortable)
+	c, _ := New()
+	c.SetProfile(portable)
+	for _, script := range []string{"<>.", "-+."} {
+		var minified bytes.Buffer
+		if _, err := c.Minify(bytes.NewBufferString(script), &minified); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		original := c.Compile(bytes.NewBufferString(script), nil, &bytes.Buffer{})
+		err := c.Compile(&minified, nil, &bytes.Buffer{})
+		if original == nil || err == nil || err.Error() != original.Error() {
+			t.Fatalf("%q: error %v expected but was %v", script, original, err)
+		}
+	}
+}
+
+func TestCompiler_MinifiedScripts(t *testing.T) {
+	compiler, error := New()
+	if error != nil {
+		t.Fatalf("error should be nil")
+	}
+	for _, test := range scripts {
+		t.Run(test.name, func(t *testing.T) {
+			var minified, buf bytes.Buffer
+			if _, error := compiler.Minify(bytes.NewBufferString(test.script), &minified); error != nil {
+				t.Fatalf("unexpected error %v", error)
+			}
+			if minified.Len() > len(test.script) {
+				t.Fatalf("minified script is longer than original")
+			}
+			if error := compiler.Compile(&minified, bytes.NewBuffer(test.input), &buf); error != nil {
+				t.Fatalf("unexpected error %v", error)
+			}
+			if !bytes.Equal(buf.Bytes(), test.result) {
+				t.Fatalf("wrong result value expected %v but was %v", test.result, buf.Bytes())
+			}
+		})
+	}
+}
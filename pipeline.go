@@ -0,0 +1,131 @@
+package compiler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+//errStageFailed closes input of the stage after the previous stage fails. it is not reported
+var errStageFailed = errors.New("previous stage failed")
+
+//errStageFinished closes output of the stage after the next stage finishes. it is not reported
+var errStageFinished = errors.New("next stage finished")
+
+//Stage is a step of the pipeline. it reads output of the previous stage and writes input of the next one
+type Stage struct {
+	Name string
+	Run  func(input io.Reader, output io.Writer) error
+}
+
+//returns stage executing script. memory is configured by the compiler profile
+func ScriptStage(c Compiler, name string, script io.Reader) Stage {
+	return Stage{Name: name, Run: func(input io.Reader, output io.Writer) error {
+		return c.Run(script, c.NewContext(input, output))
+	}}
+}
+
+//returns stage copying data read from the reader returned by transform, for example by bufio.NewReader
+func ReaderStage(name string, transform func(input io.Reader) io.Reader) Stage {
+	return Stage{Name: name, Run: func(input io.Reader, output io.Writer) error {
+		_, err := io.Copy(output, transform(input))
+		return err
+	}}
+}
+
+//returns stage copying input to the writer returned by transform, for example by hex.Dumper. the writer is closed at the end
+func WriterStage(name string, transform func(output io.Writer) io.WriteCloser) Stage {
+	return Stage{Name: name, Run: func(input io.Reader, output io.Writer) error {
+		writer := transform(output)
+		if _, err := io.Copy(writer, input); err != nil {
+			writer.Close()
+			return err
+		}
+		return writer.Close()
+	}}
+}
+
+//StageError is an error of a single pipeline stage
+type StageError struct {
+	//index of the stage starting from 1
+	Stage int
+	Name  string
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("stage %v %v: %v", e.Stage, e.Name, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+//PipelineError contains errors of failed stages in the order of stages
+type PipelineError []*StageError
+
+func (e PipelineError) Error() string {
+	var messages []string
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+//Pipeline is a chain of stages. output of every stage is connected to input of the next one
+type Pipeline []Stage
+
+/*
+run all stages concurrently. the first stage reads input and the last one writes output. stages are connected
+by in-memory pipes, so writing stage waits until the next stage reads its data. when a stage fails input
+of the next stage returns error and when a stage finishes writing of the previous stage returns error,
+such errors are not reported. returns PipelineError if any stage fails
+*/
+func (p Pipeline) Run(input io.Reader, output io.Writer) error {
+	errs := make([]error, len(p))
+	var wait sync.WaitGroup
+	var previous *io.PipeReader
+	for i := range p {
+		var reader io.Reader = input
+		if previous != nil {
+			reader = previous
+		}
+		var writer io.Writer = output
+		var next *io.PipeReader
+		var pipe *io.PipeWriter
+		if i < len(p)-1 {
+			next, pipe = io.Pipe()
+			writer = pipe
+		}
+		wait.Add(1)
+		go func(i int, reader io.Reader, writer io.Writer, previous *io.PipeReader, pipe *io.PipeWriter) {
+			defer wait.Done()
+			err := p[i].Run(reader, writer)
+			if previous != nil {
+				previous.CloseWithError(errStageFinished)
+			}
+			if pipe != nil {
+				if err != nil {
+					pipe.CloseWithError(errStageFailed)
+				} else {
+					pipe.Close()
+				}
+			}
+			errs[i] = err
+		}(i, reader, writer, previous, pipe)
+		previous = next
+	}
+	wait.Wait()
+	var result PipelineError
+	for i, err := range errs {
+		if err != nil && !errors.Is(err, errStageFailed) && !errors.Is(err, errStageFinished) {
+			result = append(result, &StageError{Stage: i + 1, Name: p[i].Name, Err: err})
+		}
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
@@ -0,0 +1,59 @@
+package compiler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestPipeline_Run(t *testing.T) {
+	c, _ := New()
+	pipeline := Pipeline{
+		ReaderStage("buffer", func(input io.Reader) io.Reader { return bufio.NewReader(input) }),
+		//increments every input byte
+		ScriptStage(c, "increment", strings.NewReader("#!bf eof=0\n,[+.,]")),
+		ScriptStage(c, "reverse", strings.NewReader("#!bf eof=0\n>,[>,]<[.<]")),
+		WriterStage("hex", func(output io.Writer) io.WriteCloser { return hex.Dumper(output) }),
+	}
+	var output bytes.Buffer
+	if err := pipeline.Run(strings.NewReader("HAL"), &output); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := hex.Dump([]byte("MBI"))
+	if output.String() != expected {
+		t.Fatalf("expected %q but was %q", expected, output.String())
+	}
+}
+
+func TestPipeline_Errors(t *testing.T) {
+	c, _ := New()
+	failure := errors.New("failure")
+	pipeline := Pipeline{
+		ScriptStage(c, "endless", strings.NewReader("+[.]")),
+		//reads three bytes only
+		ScriptStage(c, "head", strings.NewReader(",.,.,.")),
+		ScriptStage(c, "error", strings.NewReader(",.<")),
+		Stage{Name: "copy", Run: func(input io.Reader, output io.Writer) error {
+			_, err := io.Copy(output, input)
+			return err
+		}},
+		Stage{Name: "go", Run: func(input io.Reader, output io.Writer) error {
+			return failure
+		}},
+	}
+	err := pipeline.Run(strings.NewReader(""), &bytes.Buffer{})
+	errs, ok := err.(PipelineError)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("two stage errors expected but was %v", err)
+	}
+	if err.Error() != "stage 3 error: index is out of range; stage 5 go: failure" {
+		t.Fatalf("wrong error %v", err)
+	}
+	if !errors.Is(errs[1], failure) {
+		t.Fatalf("stage error should wrap the original one")
+	}
+}
@@ -0,0 +1,620 @@
+package compiler
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/gdtrp/brainfuck/stack"
+	"io"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+//PreprocessError describes problem found by preprocessor. position points into the original script
+type PreprocessError struct {
+	stack.Position
+	Message string
+}
+
+func (e *PreprocessError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Position, e.Message)
+}
+
+//macro defined by #define directive
+type macro struct {
+	params []string
+	body   string
+}
+
+//conditional section opened by #if, #ifdef or #ifndef
+type condition struct {
+	start stack.Position
+	//section of the condition is included
+	active bool
+	//#else is already found
+	inElse bool
+}
+
+//repetition group opened by '{'
+type group struct {
+	start   stack.Position
+	content []byte
+	//newlines removed from the content to keep line numbers of the following lines
+	newlines int
+}
+
+//maximal size of content repeated inside of repetition group. outer groups are repeated while the output is read
+const maxGroupSize = 1 << 20
+
+//output data repeated count times
+type chunk struct {
+	data  []byte
+	count int
+	//number of bytes of the current repetition already read
+	offset int
+}
+
+//file which includes the current one
+type source struct {
+	reader *bufio.Reader
+	file   fs.File
+	name   string
+	line   int
+	//number of conditions opened before the file
+	conditions int
+	//include directive is followed by newline
+	newline bool
+}
+
+//output lines starting from the first one belong to the file starting from the line
+type segment struct {
+	first int
+	name  string
+	line  int
+}
+
+//preprocessor transforms script line by line. only open repetition groups are buffered
+type preprocessor struct {
+	options  map[string]string
+	includes []fs.FS
+	macros   map[string]macro
+	//current file with the number of the current line. name is empty for the script itself
+	current source
+	parents []source
+	//number of the current output line with files of the output lines
+	lines      int
+	segments   []segment
+	conditions []condition
+	groups     []*group
+	out        []chunk
+	err        error
+}
+
+/*
+returns reader of preprocessed script. supported directives are placed on separate lines:
+"#define NAME body" and "#define NAME(a, b) body" define macros expanded where the following text invokes them
+as "$NAME" or "$NAME(x, y)", names without '$' are not expanded, so comments are kept. parameters are replaced by
+arguments. arguments are separated by commas, an argument wrapped in parentheses can contain commas.
+"#undef NAME" removes macro. "#ifdef NAME" and "#ifndef NAME" check macro or compiler option,
+"#if NAME" checks that option is set to a value other than "", "0" or "false", "#if NAME=VALUE" compares option value.
+conditional sections end with "#endif" and can have "#else" section.
+"#include "path"" inserts preprocessed file found in the include path of the compiler or in Stdlib.
+"{body}*N" repeats body N times, groups can be nested and span several lines.
+other lines starting with '#' are kept. directive lines are replaced by empty lines and newlines inside
+repetition groups are moved after the group, so line numbers of the output match the original script.
+files and lines of compiled operations point into included files, columns are columns of the preprocessed line
+*/
+func (c Compiler) Preprocess(script io.Reader) io.Reader {
+	return &preprocessor{
+		current:  source{reader: bufio.NewReader(script)},
+		options:  c.options,
+		includes: append(append([]fs.FS(nil), c.includes...), Stdlib),
+		macros:   make(map[string]macro),
+		lines:    1,
+		segments: []segment{{first: 1, line: 1}},
+	}
+}
+
+//set file systems searched for included files. Stdlib is searched after them
+func (c *Compiler) SetIncludePath(paths ...fs.FS) {
+	c.includes = append([]fs.FS(nil), paths...)
+}
+
+//set compiler option checked by preprocessor conditions. compilers copied before the call are not changed
+func (c *Compiler) SetOption(name string, value string) {
+	options := make(map[string]string, len(c.options)+1)
+	for k, v := range c.options {
+		options[k] = v
+	}
+	options[name] = value
+	c.options = options
+}
+
+//returns value of compiler option
+func (c Compiler) Option(name string) (string, bool) {
+	value, found := c.options[name]
+	return value, found
+}
+
+func (p *preprocessor) Read(b []byte) (int, error) {
+	for len(p.out) == 0 && p.err == nil {
+		p.err = p.next()
+	}
+	if len(p.out) == 0 {
+		return 0, p.err
+	}
+	c := &p.out[0]
+	n := copy(b, c.data[c.offset:])
+	if c.offset += n; c.offset == len(c.data) {
+		c.offset = 0
+		if c.count--; c.count == 0 {
+			p.out = p.out[1:]
+		}
+	}
+	return n, nil
+}
+
+//process next line of the script. returns io.EOF after the last line
+func (p *preprocessor) next() error {
+	line, err := p.current.reader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if line == "" && err == io.EOF {
+		if len(p.parents) > 0 {
+			return p.endInclude()
+		}
+		return p.finish()
+	}
+	p.current.line++
+	text := strings.TrimSuffix(line, "\n")
+	if directive, args, found := p.directive(text); found {
+		if directive == "include" && p.active() {
+			return p.include(args, strings.HasSuffix(line, "\n"))
+		}
+		if err := p.handle(directive, args); err != nil {
+			return err
+		}
+	} else if strings.HasPrefix(strings.TrimSpace(text), "#") {
+		if p.active() {
+			p.write([]byte(text), false)
+		}
+	} else if p.active() {
+		if err := p.process(text); err != nil {
+			return err
+		}
+	}
+	if strings.HasSuffix(line, "\n") {
+		p.write([]byte{'\n'}, true)
+	}
+	return nil
+}
+
+//check open sections at the end of the script
+func (p *preprocessor) finish() error {
+	if len(p.conditions) > 0 {
+		return p.error(p.conditions[len(p.conditions)-1].start, "missing #endif")
+	}
+	if len(p.groups) > 0 {
+		return p.error(p.groups[len(p.groups)-1].start, "missing closing brace")
+	}
+	return io.EOF
+}
+
+//start reading included file
+func (p *preprocessor) include(args string, newline bool) error {
+	position := p.position(1)
+	name, err := strconv.Unquote(args)
+	if err != nil || !fs.ValidPath(name) {
+		return p.error(position, "#include requires a quoted file path")
+	}
+	if len(p.groups) > 0 {
+		return p.error(position, "#include inside of repetition group")
+	}
+	for _, parent := range append(p.parents, p.current) {
+		if parent.name == name {
+			var chain []string
+			for _, s := range append(p.parents[1:], p.current) {
+				chain = append(chain, s.name)
+			}
+			return p.error(position, "include cycle: %v -> %v", strings.Join(chain, " -> "), name)
+		}
+	}
+	var file fs.File
+	for _, path := range p.includes {
+		if file, err = path.Open(name); err == nil {
+			break
+		}
+	}
+	if file == nil {
+		return p.error(position, "included file %v is not found", name)
+	}
+	p.current.newline = newline
+	p.parents = append(p.parents, p.current)
+	p.current = source{reader: bufio.NewReader(file), file: file, name: name, conditions: len(p.conditions)}
+	p.segments = append(p.segments, segment{first: p.lines, name: name, line: 1})
+	return nil
+}
+
+//return to the file which included the current one
+func (p *preprocessor) endInclude() error {
+	if len(p.conditions) > p.current.conditions {
+		return p.error(p.conditions[len(p.conditions)-1].start, "missing #endif")
+	}
+	if len(p.groups) > 0 {
+		return p.error(p.groups[len(p.groups)-1].start, "missing closing brace")
+	}
+	p.current.file.Close()
+	p.current = p.parents[len(p.parents)-1]
+	p.parents = p.parents[:len(p.parents)-1]
+	if p.current.newline {
+		p.write([]byte{'\n'}, true)
+		p.segments = append(p.segments, segment{first: p.lines, name: p.current.name, line: p.current.line + 1})
+	}
+	return nil
+}
+
+//returns position in the original file of the position in the output
+func (p *preprocessor) locate(position stack.Position) stack.Position {
+	for i := len(p.segments) - 1; i >= 0; i-- {
+		if s := p.segments[i]; s.first <= position.Line {
+			position.File = s.name
+			position.Line = s.line + position.Line - s.first
+			break
+		}
+	}
+	return position
+}
+
+//returns position of the column in the current line
+func (p *preprocessor) position(column int) stack.Position {
+	return stack.Position{File: p.current.name, Line: p.current.line, Column: column}
+}
+
+func (p *preprocessor) error(position stack.Position, format string, args ...interface{}) error {
+	return &PreprocessError{Position: position, Message: fmt.Sprintf(format, args...)}
+}
+
+//returns directive name with arguments if line is a supported directive
+func (p *preprocessor) directive(line string) (string, string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "#") {
+		return "", "", false
+	}
+	fields := strings.SplitN(trimmed[1:], " ", 2)
+	switch fields[0] {
+	case "define", "undef", "if", "ifdef", "ifndef", "else", "endif", "include":
+		if len(fields) == 1 {
+			return fields[0], "", true
+		}
+		return fields[0], strings.TrimSpace(fields[1]), true
+	}
+	return "", "", false
+}
+
+//returns true if all conditional sections are included
+func (p *preprocessor) active() bool {
+	return len(p.conditions) == 0 || p.conditions[len(p.conditions)-1].active
+}
+
+func (p *preprocessor) handle(directive string, args string) error {
+	position := p.position(1)
+	switch directive {
+	case "if", "ifdef", "ifndef":
+		if args == "" {
+			return p.error(position, "#%v requires a name", directive)
+		}
+		p.conditions = append(p.conditions, condition{start: position, active: p.active() && p.check(directive, args)})
+	case "else":
+		if len(p.conditions) == p.current.conditions || p.conditions[len(p.conditions)-1].inElse {
+			return p.error(position, "#else without #if")
+		}
+		c := &p.conditions[len(p.conditions)-1]
+		parent := len(p.conditions) == 1 || p.conditions[len(p.conditions)-2].active
+		c.active, c.inElse = parent && !c.active, true
+	case "endif":
+		if len(p.conditions) == p.current.conditions {
+			return p.error(position, "#endif without #if")
+		}
+		p.conditions = p.conditions[:len(p.conditions)-1]
+	case "define":
+		if p.active() {
+			return p.define(args, position)
+		}
+	case "undef":
+		if p.active() {
+			delete(p.macros, args)
+		}
+	}
+	return nil
+}
+
+//evaluate condition
+func (p *preprocessor) check(directive string, args string) bool {
+	switch directive {
+	case "ifdef", "ifndef":
+		_, option := p.options[args]
+		_, defined := p.macros[args]
+		return (option || defined) == (directive == "ifdef")
+	}
+	if i := strings.Index(args, "="); i >= 0 {
+		value, found := p.options[strings.TrimSpace(args[:i])]
+		return found && value == strings.TrimSpace(args[i+1:])
+	}
+	value := p.options[args]
+	return value != "" && value != "0" && value != "false"
+}
+
+//parse macro definition
+func (p *preprocessor) define(args string, position stack.Position) error {
+	name := identifier(args)
+	if name == "" {
+		return p.error(position, "#define requires a name")
+	}
+	rest := args[len(name):]
+	var params []string
+	if strings.HasPrefix(rest, "(") {
+		end := strings.Index(rest, ")")
+		if end < 0 {
+			return p.error(position, "missing closing parenthesis in parameters of %v", name)
+		}
+		for _, param := range strings.Split(rest[1:end], ",") {
+			param = strings.TrimSpace(param)
+			if identifier(param) != param || param == "" {
+				return p.error(position, "wrong parameter %q of %v", param, name)
+			}
+			params = append(params, param)
+		}
+		rest = rest[end+1:]
+	}
+	p.macros[name] = macro{params: params, body: strings.TrimSpace(rest)}
+	return nil
+}
+
+//returns identifier at the start of text
+func identifier(text string) string {
+	i := 0
+	for i < len(text) && (isIdentifier(text[i]) && (i > 0 || text[i] < '0' || text[i] > '9')) {
+		i++
+	}
+	return text[:i]
+}
+
+func isIdentifier(b byte) bool {
+	return b == '_' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9'
+}
+
+//text with original column of every byte
+type expansion struct {
+	text    []byte
+	columns []int
+}
+
+func (e *expansion) append(text string, column int) {
+	for i := 0; i < len(text); i++ {
+		e.text = append(e.text, text[i])
+		e.columns = append(e.columns, column)
+	}
+}
+
+//macroSigil starts macro invocation
+const macroSigil = '$'
+
+/*
+expand macros of the text. columns are original columns of the text bytes, expanded macros get the column of their name.
+arguments are expanded before substitution. expanding contains names of macros being expanded.
+expansion including nested macros is limited by maxGroupSize
+*/
+func (p *preprocessor) expand(text string, columns []int, expanding []string, result *expansion) error {
+	for i := 0; i < len(text); {
+		position := p.position(columns[i])
+		name := ""
+		if text[i] == macroSigil {
+			name = identifier(text[i+1:])
+		}
+		m, found := p.macros[name]
+		if !found {
+			if len(result.text) >= maxGroupSize {
+				return p.error(position, "macro expansion is larger than %d bytes", maxGroupSize)
+			}
+			result.append(text[i:i+1], columns[i])
+			i++
+			continue
+		}
+		i += 1 + len(name)
+		for _, e := range expanding {
+			if e == name {
+				return p.error(position, "recursive expansion of %v", name)
+			}
+		}
+		body := m.body
+		if len(m.params) > 0 {
+			args, n, err := arguments(text[i:])
+			if err != nil {
+				return p.error(position, "%v: %v", name, err)
+			}
+			if len(args) != len(m.params) {
+				return p.error(position, "%v expects %d arguments but was %d", name, len(m.params), len(args))
+			}
+			i += n
+			for j, arg := range args {
+				var expanded expansion
+				if err := p.expand(arg, repeat(position.Column, len(arg)), expanding, &expanded); err != nil {
+					return err
+				}
+				args[j] = string(expanded.text)
+			}
+			body = substitute(body, m.params, args)
+		}
+		if err := p.expand(body, repeat(position.Column, len(body)), append(expanding, name), result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func repeat(value int, n int) []int {
+	result := make([]int, n)
+	for i := range result {
+		result[i] = value
+	}
+	return result
+}
+
+//expand macros and repetitions of the line
+func (p *preprocessor) process(line string) error {
+	columns := make([]int, len(line))
+	for i := range columns {
+		columns[i] = i + 1
+	}
+	var e expansion
+	if err := p.expand(line, columns, nil, &e); err != nil {
+		return err
+	}
+	text := e.text
+	for i := 0; i < len(text); {
+		position := p.position(e.columns[i])
+		switch text[i] {
+		case '{':
+			p.groups = append(p.groups, &group{start: position})
+		case '}':
+			if len(p.groups) == 0 {
+				return p.error(position, "missing opening brace")
+			}
+			digits := 0
+			if i+1 < len(text) && text[i+1] == '*' {
+				for i+2+digits < len(text) && text[i+2+digits] >= '0' && text[i+2+digits] <= '9' {
+					digits++
+				}
+			}
+			if digits == 0 {
+				return p.error(position, "repetition count expected after closing brace")
+			}
+			count, err := strconv.Atoi(string(text[i+2 : i+2+digits]))
+			if err != nil {
+				return p.error(position, "repetition count expected after closing brace")
+			}
+			g := p.groups[len(p.groups)-1]
+			p.groups = p.groups[:len(p.groups)-1]
+			if err := p.repeat(g, count); err != nil {
+				return err
+			}
+			for j := 0; j < g.newlines; j++ {
+				p.write([]byte{'\n'}, true)
+			}
+			i += 2 + digits
+			continue
+		default:
+			p.write(text[i:i+1], false)
+		}
+		i++
+	}
+	return nil
+}
+
+/*
+write content of the closed group repeated count times. outer group is repeated while the output is read,
+content of nested group repeated inside of the outer one is limited by maxGroupSize
+*/
+func (p *preprocessor) repeat(g *group, count int) error {
+	if count == 0 || len(g.content) == 0 {
+		return nil
+	}
+	if len(p.groups) == 0 {
+		p.out = append(p.out, chunk{data: g.content, count: count})
+		return nil
+	}
+	outer := p.groups[len(p.groups)-1]
+	if count > (maxGroupSize-len(outer.content))/len(g.content) {
+		return p.error(g.start, "repetition group is larger than %d bytes", maxGroupSize)
+	}
+	for i := 0; i < count; i++ {
+		outer.content = append(outer.content, g.content...)
+	}
+	return nil
+}
+
+/*
+write data to the innermost open group or to the output. newline inside a group is moved after it
+*/
+func (p *preprocessor) write(data []byte, newline bool) {
+	if len(p.groups) == 0 {
+		if last := len(p.out) - 1; last >= 0 && p.out[last].count == 1 {
+			p.out[last].data = append(p.out[last].data, data...)
+		} else {
+			p.out = append(p.out, chunk{data: append([]byte(nil), data...), count: 1})
+		}
+		if newline {
+			p.lines++
+		}
+		return
+	}
+	g := p.groups[len(p.groups)-1]
+	if newline {
+		g.newlines++
+	} else {
+		g.content = append(g.content, data...)
+	}
+}
+
+//parse arguments in parentheses. returns arguments and number of parsed bytes
+func arguments(text string) ([]string, int, error) {
+	if !strings.HasPrefix(text, "(") {
+		return nil, 0, fmt.Errorf("arguments expected")
+	}
+	var args []string
+	depth, start := 0, 1
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return append(args, argument(text[start:i])), i + 1, nil
+			}
+		case ',':
+			if depth == 1 {
+				args = append(args, argument(text[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return nil, 0, fmt.Errorf("missing closing parenthesis")
+}
+
+//trim argument and remove parentheses wrapping the whole argument
+func argument(text string) string {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "(") && strings.HasSuffix(text, ")") {
+		if _, n, err := arguments(text); err == nil && n == len(text) {
+			return text[1 : len(text)-1]
+		}
+	}
+	return text
+}
+
+//replace parameter names of the body with arguments
+func substitute(body string, params []string, args []string) string {
+	var result strings.Builder
+	for i := 0; i < len(body); {
+		if isIdentifier(body[i]) && (body[i] < '0' || body[i] > '9') {
+			name := identifier(body[i:])
+			i += len(name)
+			replaced := false
+			for j, param := range params {
+				if param == name {
+					result.WriteString(args[j])
+					replaced = true
+				}
+			}
+			if !replaced {
+				result.WriteString(name)
+			}
+			continue
+		}
+		result.WriteByte(body[i])
+		i++
+	}
+	return result.String()
+}
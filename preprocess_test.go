@@ -0,0 +1,99 @@
+package compiler
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func preprocess(c Compiler, script string) (string, error) {
+	result, err := ioutil.ReadAll(iotest.OneByteReader(c.Preprocess(strings.NewReader(script))))
+	return string(result), err
+}
+
+func TestCompiler_Preprocess(t *testing.T) {
+	c, _ := New()
+	c.SetOption("cells", "16")
+	c.SetOption("debug", "0")
+	for _, test := range []struct {
+		name     string
+		script   string
+		expected string
+	}{
+		{"no directives", "+[-]>.\n<", "+[-]>.\n<"},
+		{"macro", "#define ZERO [-]\n+$ZERO.", "\n+[-]."},
+		{"macro with parameters", "#define MOVE(from, to) from[-to+from]\n$MOVE(<, >)", "\n<[->+<]"},
+		{"nested macro in argument", "#define TWICE(x) x x\n$TWICE($TWICE(+))", "\n+ + + +"},
+		{"argument with comma", "#define READ(x) x\n$READ((,.))", "\n,."},
+		{"undef", "#define A +\n#undef A\n$A", "\n\n$A"},
+		{"comment", "#define a +\nthis is a test. $a", "\nthis is a test. +"},
+		{"macro in body", "#define INC +\n#define TWO $INC$INC\n$TWO $", "\n\n++ $"},
+		{"repetition", "{+}*3>{{-}*2.}*2", "+++>--.--."},
+		{"multiline repetition", "{+\n-}*2\n.", "+-+-\n\n."},
+		{"if option value", "#if cells=16\n+\n#else\n-\n#endif", "\n+\n\n\n"},
+		{"if false option", "#if debug\n+\n#endif\n#ifndef debug\n-\n#endif\n#ifdef cells\n.\n#endif", "\n\n\n\n\n\n\n.\n"},
+		{"nested conditions", "#ifdef missing\n#if cells\n+\n#else\n-\n#endif\n#else\n.\n#endif", "\n\n\n\n\n\n\n.\n"},
+		{"other hash lines", "#!bf cells=16\n#define X +\n# X comment", "#!bf cells=16\n\n# X comment"},
+	} {
+		result, err := preprocess(c, test.script)
+		if err != nil {
+			t.Fatalf("%v: unexpected error %v", test.name, err)
+		}
+		if result != test.expected {
+			t.Fatalf("%v: expected %q but was %q", test.name, test.expected, result)
+		}
+	}
+}
+
+func TestCompiler_PreprocessErrors(t *testing.T) {
+	c, _ := New()
+	for _, test := range []struct {
+		script string
+		error  string
+	}{
+		{"+\n#define A $B\n#define B $A\n  $A", "4:3: recursive expansion of A"},
+		{"#define F(a) a\n$F", "2:1: F: arguments expected"},
+		{"#define F(a) a\n $F(+,-)", "2:2: F expects 1 arguments but was 2"},
+		{"+\n{+\n", "2:1: missing closing brace"},
+		{"+}*2", "1:2: missing opening brace"},
+		{"{+}", "1:3: repetition count expected after closing brace"},
+		{"{+}*99999999999999999999", "1:3: repetition count expected after closing brace"},
+		{"{{+}*9999999999999999}*2", "1:2: repetition group is larger than 1048576 bytes"},
+		{"#define A ++++++++\n#define B $A$A$A$A$A$A$A$A\n#define C $B$B$B$B$B$B$B$B\n#define D $C$C$C$C$C$C$C$C\n" +
+			"#define E $D$D$D$D$D$D$D$D\n#define F $E$E$E$E$E$E$E$E\n#define G $F$F$F$F$F$F$F$F\n+$G", "8:2: macro expansion is larger than 1048576 bytes"},
+		{"\n#if x\n", "2:1: missing #endif"},
+		{"#endif", "1:1: #endif without #if"},
+	} {
+		_, err := preprocess(c, test.script)
+		if _, ok := err.(*PreprocessError); !ok || err.Error() != test.error {
+			t.Fatalf("%q: error %q expected but was %v", test.script, test.error, err)
+		}
+	}
+}
+
+func TestCompiler_PreprocessLargeRepetition(t *testing.T) {
+	c, _ := New()
+	//repetitions are produced while the output is read
+	var output bytes.Buffer
+	if _, err := io.CopyN(&output, c.Preprocess(strings.NewReader("-{+}*9999999999999999.")), 1<<20); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if output.String() != "-"+strings.Repeat("+", 1<<20-1) {
+		t.Fatalf("wrong output %.20q", output.String())
+	}
+}
+
+func TestCompiler_PreprocessCompile(t *testing.T) {
+	c, _ := New()
+	script := "#define ADD(n) {+}*n\n$ADD(10)[>$ADD(6)<-]>{+}*5."
+	var output bytes.Buffer
+	if err := c.Compile(c.Preprocess(strings.NewReader(script)), nil, &output); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if output.String() != "A" {
+		t.Fatalf("wrong output %q", output.String())
+	}
+}
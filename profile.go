@@ -0,0 +1,100 @@
+package compiler
+
+import (
+	"fmt"
+	"github.com/gdtrp/brainfuck/stack"
+	"io"
+	"sort"
+)
+
+/*
+Profile bundles memory settings matching behavior of a particular interpreter. scripts relying on the quirks
+of the interpreter produce the same results when they are run with its profile. script header pragmas
+override the profile settings unless they are forbidden by Compiler.AllowPragmas
+*/
+type Profile struct {
+	Name string
+	//number of bytes in memory cell: 1, 2 or 4
+	CellWidth int
+	//result of incrementing the maximal cell value and decrementing zero
+	Overflow stack.OverflowBehavior
+	//value stored by input operation at the end of input
+	EOF stack.EOFBehavior
+	//initial pointer position
+	Pointer int
+	//initial number of memory cells
+	Cells int
+	//memory grows when pointer moves past its end
+	Grow bool
+}
+
+//names of predefined profiles
+const (
+	//classic interpreter: 30000 byte cells wrapping around, input leaves the cell unchanged at the end of input
+	ProfileClassic = "classic"
+	//16 bit cells wrapping around on tape growing to the right without limit, zero is stored at the end of input
+	ProfileWide = "wide"
+	//behavior portable between interpreters: 30000 byte cells, overflow of cell stops execution
+	//as well as moving pointer out of the tape, input leaves the cell unchanged at the end of input
+	ProfilePortable = "portable"
+)
+
+//predefined profiles by name. they are returned by value, so callers can not change them
+var profiles = map[string]Profile{
+	ProfileClassic:  {Name: ProfileClassic, CellWidth: 1, Cells: 30000},
+	ProfileWide:     {Name: ProfileWide, CellWidth: 2, EOF: stack.EOFZero, Cells: 30000, Grow: true},
+	ProfilePortable: {Name: ProfilePortable, CellWidth: 1, Overflow: stack.OverflowError, Cells: 30000},
+}
+
+//returns predefined profile by name
+func LookupProfile(name string) (Profile, bool) {
+	p, found := profiles[name]
+	return p, found
+}
+
+//returns names of predefined profiles in alphabetical order
+func ProfileNames() []string {
+	var result []string
+	for name := range profiles {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+//configure memory of the context by the profile. memory is cleared
+func (p Profile) Configure(context *stack.Context) error {
+	if err := context.Configure(p.CellWidth, p.Cells); err != nil {
+		return fmt.Errorf("profile %v: %v", p.Name, err)
+	}
+	if p.Pointer < 0 || p.Pointer >= p.Cells {
+		return fmt.Errorf("profile %v: pointer is out of range", p.Name)
+	}
+	context.CurrentIdx = p.Pointer
+	context.Overflow, context.EOF, context.Grow = p.Overflow, p.EOF, p.Grow
+	return nil
+}
+
+/*
+set profile of contexts created by Compile and NewContext. returns error if the profile can't configure a context.
+compilers copied before the call are not changed
+*/
+func (c *Compiler) SetProfile(profile Profile) error {
+	//profile is checked once so that configuring a context never fails
+	if err := profile.Configure(stack.NewContextWithMemorySize(nil, nil, 1)); err != nil {
+		return err
+	}
+	c.profile = &profile
+	return nil
+}
+
+/*
+create new context configured by the compiler profile. context is created with default settings if there is no profile
+*/
+func (c Compiler) NewContext(reader io.Reader, writer io.Writer) *stack.Context {
+	context := stack.NewContext(reader, writer)
+	if c.profile != nil {
+		c.profile.Configure(context)
+	}
+	return context
+}
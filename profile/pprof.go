@@ -0,0 +1,192 @@
+package profile
+
+import (
+	"compress/gzip"
+	"github.com/gdtrp/brainfuck/stack"
+	"io"
+)
+
+//field numbers of profile.proto messages used by go tool pprof
+const (
+	profileSampleType    = 1
+	profileSample        = 2
+	profileLocation      = 4
+	profileFunction      = 5
+	profileStringTable   = 6
+	profilePeriodType    = 11
+	profilePeriod        = 12
+	profileDefaultSample = 14
+	valueTypeType        = 1
+	valueTypeUnit        = 2
+	sampleLocation       = 1
+	sampleValue          = 2
+	locationID           = 1
+	locationLine         = 4
+	lineFunction         = 1
+	lineLine             = 2
+	lineColumn           = 3
+	functionID           = 1
+	functionName         = 2
+	functionSystemName   = 3
+	functionFilename     = 4
+	functionStartLine    = 5
+	protoVarint          = 0
+	protoLengthDelimited = 2
+)
+
+//protoBuffer encodes protocol buffers messages
+type protoBuffer []byte
+
+func (b *protoBuffer) varint(x uint64) {
+	for x >= 0x80 {
+		*b = append(*b, byte(x)|0x80)
+		x >>= 7
+	}
+	*b = append(*b, byte(x))
+}
+
+func (b *protoBuffer) key(field int, wireType int) {
+	b.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (b *protoBuffer) uint64(field int, x uint64) {
+	b.key(field, protoVarint)
+	b.varint(x)
+}
+
+//write integer field skipping default zero value
+func (b *protoBuffer) int64(field int, x int64) {
+	if x == 0 {
+		return
+	}
+	b.uint64(field, uint64(x))
+}
+
+func (b *protoBuffer) bytes(field int, data []byte) {
+	b.key(field, protoLengthDelimited)
+	b.varint(uint64(len(data)))
+	*b = append(*b, data...)
+}
+
+func (b *protoBuffer) packed(field int, values []uint64) {
+	var packed protoBuffer
+	for _, x := range values {
+		packed.varint(x)
+	}
+	b.bytes(field, packed)
+}
+
+//pprofBuilder collects string table, functions and locations of encoded profile
+type pprofBuilder struct {
+	buf       protoBuffer
+	strings   map[string]int64
+	functions map[int]uint64
+	locations map[pprofLocation]uint64
+	filename  int64
+}
+
+//location is identified by script position and function it belongs to
+type pprofLocation struct {
+	offset   int
+	function uint64
+}
+
+func (b *pprofBuilder) string(s string) int64 {
+	if index, found := b.strings[s]; found {
+		return index
+	}
+	index := int64(len(b.strings))
+	b.strings[s] = index
+	b.buf.bytes(profileStringTable, []byte(s))
+	return index
+}
+
+//returns id of pseudo function of the loop started at start position. zero position stands for the whole script
+func (b *pprofBuilder) function(start stack.Position) uint64 {
+	key := start.Offset
+	if start.Line == 0 {
+		key = -1
+	}
+	if id, found := b.functions[key]; found {
+		return id
+	}
+	id := uint64(len(b.functions) + 1)
+	b.functions[key] = id
+	name := "main"
+	if start.Line != 0 {
+		name = "loop at " + start.String()
+	}
+	var f protoBuffer
+	f.uint64(functionID, id)
+	f.int64(functionName, b.string(name))
+	f.int64(functionSystemName, b.string(name))
+	f.int64(functionFilename, b.filename)
+	f.int64(functionStartLine, int64(start.Line))
+	b.buf.bytes(profileFunction, f)
+	return id
+}
+
+func (b *pprofBuilder) location(position stack.Position, function uint64) uint64 {
+	key := pprofLocation{offset: position.Offset, function: function}
+	if id, found := b.locations[key]; found {
+		return id
+	}
+	id := uint64(len(b.locations) + 1)
+	b.locations[key] = id
+	var line protoBuffer
+	line.uint64(lineFunction, function)
+	line.int64(lineLine, int64(position.Line))
+	line.int64(lineColumn, int64(position.Column))
+	var l protoBuffer
+	l.uint64(locationID, id)
+	l.bytes(locationLine, line)
+	b.buf.bytes(profileLocation, l)
+	return id
+}
+
+func (b *pprofBuilder) valueType(field int, name string, unit string) {
+	var v protoBuffer
+	v.int64(valueTypeType, b.string(name))
+	v.int64(valueTypeUnit, b.string(unit))
+	b.buf.bytes(field, v)
+}
+
+/*
+write collected statistics as gzipped pprof profile readable by go tool pprof. every loop is represented as
+pseudo function named "loop at line:col" and nested loops are represented as stack frames.
+profile contains two sample values: number of executed operations and time spent in custom operations.
+filename is used as source file of all functions
+*/
+func (p *Profiler) WritePprof(writer io.Writer, filename string) error {
+	b := &pprofBuilder{
+		strings:   make(map[string]int64),
+		functions: make(map[int]uint64),
+		locations: make(map[pprofLocation]uint64),
+	}
+	//string table must start with empty string
+	b.string("")
+	b.filename = b.string(filename)
+	b.valueType(profileSampleType, "instructions", "count")
+	b.valueType(profileSampleType, "time", "nanoseconds")
+	for _, stats := range p.Operations() {
+		var frames []uint64
+		position := stats.Position
+		for _, start := range stats.Loops {
+			frames = append(frames, b.location(position, b.function(start)))
+			position = start
+		}
+		frames = append(frames, b.location(position, b.function(stack.Position{})))
+		var sample protoBuffer
+		sample.packed(sampleLocation, frames)
+		sample.packed(sampleValue, []uint64{uint64(stats.Count), uint64(stats.Time.Nanoseconds())})
+		b.buf.bytes(profileSample, sample)
+	}
+	b.valueType(profilePeriodType, "instructions", "count")
+	b.buf.int64(profilePeriod, 1)
+	b.buf.int64(profileDefaultSample, b.string("instructions"))
+	zipper := gzip.NewWriter(writer)
+	if _, err := zipper.Write(b.buf); err != nil {
+		return err
+	}
+	return zipper.Close()
+}
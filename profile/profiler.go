@@ -0,0 +1,217 @@
+/*
+Package profile collects execution statistics of brainfuck scripts.
+
+Profiler counts executions of every script position and of every loop: how many times the loop was entered,
+how many iterations it made and how many operations were executed inside of it, including nested loops.
+Time spent in custom operations is measured separately.
+*/
+package profile
+
+import (
+	"bytes"
+	compiler "github.com/gdtrp/brainfuck"
+	"github.com/gdtrp/brainfuck/stack"
+	"io"
+	"sort"
+	"time"
+)
+
+//OperationStats contains executions of operation at single script position
+type OperationStats struct {
+	Position stack.Position
+	Command  stack.Command
+	Count    int
+	//time spent in custom operation. always zero for default operations
+	Time time.Duration
+	//start positions of enclosing loops starting from the innermost
+	Loops []stack.Position
+}
+
+//LoopStats contains executions of loop started at Start position
+type LoopStats struct {
+	Start stack.Position
+	//number of times loop was reached
+	Entries int
+	//number of times loop body was executed
+	Iterations int
+	//number of operations executed inside of the loop including nested loops
+	Instructions int
+}
+
+//CustomStats contains calls and time spent in custom operation
+type CustomStats struct {
+	Command stack.Command
+	Calls   int
+	Time    time.Duration
+}
+
+//Profiler runs scripts and collects execution statistics
+type Profiler struct {
+	compiler   compiler.Compiler
+	defaults   map[stack.Command]bool
+	operations map[int]*OperationStats
+	loops      map[int]*LoopStats
+	custom     map[stack.Command]*CustomStats
+	//script source read by compiler
+	source bytes.Buffer
+	//previous executed operation
+	previous stack.OperationalElement
+	started  time.Time
+}
+
+/*
+create new profiler. statistics are accumulated over all scripts run with the profiler
+*/
+func New(c compiler.Compiler) *Profiler {
+	defaults := make(map[stack.Command]bool)
+	for _, o := range stack.GetDefaultOperations() {
+		defaults[o.Command()] = true
+	}
+	return &Profiler{
+		compiler:   c,
+		defaults:   defaults,
+		operations: make(map[int]*OperationStats),
+		loops:      make(map[int]*LoopStats),
+		custom:     make(map[stack.Command]*CustomStats),
+	}
+}
+
+/*
+compile provided script collecting statistics. read byte data from reader and write outgoing bytes to writer.
+memory is configured by the compiler profile
+*/
+func (p *Profiler) Compile(script io.Reader, reader io.Reader, writer io.Writer) error {
+	return p.Run(script, p.compiler.NewContext(reader, writer))
+}
+
+/*
+compile provided script using prepared context collecting statistics
+*/
+func (p *Profiler) Run(script io.Reader, context *stack.Context) error {
+	p.source.Reset()
+	p.previous = nil
+	before, after := context.BeforeAction, context.AfterAction
+	context.BeforeAction = func(ctx *stack.Context) error {
+		if before != nil {
+			if err := before(ctx); err != nil {
+				return err
+			}
+		}
+		p.before(ctx)
+		return nil
+	}
+	context.AfterAction = func(ctx *stack.Context) error {
+		p.after(ctx)
+		if after != nil {
+			return after(ctx)
+		}
+		return nil
+	}
+	return p.compiler.Run(compiler.WrapScript(script, io.TeeReader(script, &p.source)), context)
+}
+
+func (p *Profiler) before(ctx *stack.Context) {
+	element := ctx.Stack.Current()
+	position := element.Position()
+	command := element.Operation().Command()
+	stats, found := p.operations[position.Offset]
+	if !found {
+		stats = &OperationStats{Position: position, Command: command}
+		for loop := element.CurrentLoop(); loop != nil; loop = loop.GetPreviousLoop() {
+			stats.Loops = append(stats.Loops, loop.CurrentOperation().Position())
+		}
+		p.operations[position.Offset] = stats
+	}
+	stats.Count++
+	for loop := element.CurrentLoop(); loop != nil; loop = loop.GetPreviousLoop() {
+		p.loop(loop).Instructions++
+	}
+	if command == "[" {
+		loop := p.loop(element.CurrentLoop())
+		//loop condition is checked again after closing bracket of the same loop
+		if p.previous == nil || p.previous.Operation().Command() != "]" || p.previous.CurrentLoop() != element.CurrentLoop() {
+			loop.Entries++
+		}
+		if ctx.GetCurrentCell() != 0 {
+			loop.Iterations++
+		}
+	}
+	p.previous = element
+	if !p.defaults[command] {
+		p.started = time.Now()
+	}
+}
+
+func (p *Profiler) after(ctx *stack.Context) {
+	element := ctx.Stack.Current()
+	command := element.Operation().Command()
+	if p.defaults[command] {
+		return
+	}
+	elapsed := time.Since(p.started)
+	p.operations[element.Position().Offset].Time += elapsed
+	stats, found := p.custom[command]
+	if !found {
+		stats = &CustomStats{Command: command}
+		p.custom[command] = stats
+	}
+	stats.Calls++
+	stats.Time += elapsed
+}
+
+func (p *Profiler) loop(loop stack.LoopElement) *LoopStats {
+	start := loop.CurrentOperation().Position()
+	stats, found := p.loops[start.Offset]
+	if !found {
+		stats = &LoopStats{Start: start}
+		p.loops[start.Offset] = stats
+	}
+	return stats
+}
+
+//returns statistics of executed operations ordered by position
+func (p *Profiler) Operations() []OperationStats {
+	result := make([]OperationStats, 0, len(p.operations))
+	for _, stats := range p.operations {
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Position.Offset < result[j].Position.Offset
+	})
+	return result
+}
+
+//returns statistics of executed loops ordered by number of executed instructions starting from the hottest
+func (p *Profiler) Loops() []LoopStats {
+	result := make([]LoopStats, 0, len(p.loops))
+	for _, stats := range p.loops {
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Instructions != result[j].Instructions {
+			return result[i].Instructions > result[j].Instructions
+		}
+		return result[i].Start.Offset < result[j].Start.Offset
+	})
+	return result
+}
+
+//returns statistics of custom operations ordered by spent time starting from the slowest
+func (p *Profiler) Custom() []CustomStats {
+	result := make([]CustomStats, 0, len(p.custom))
+	for _, stats := range p.custom {
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Time != result[j].Time {
+			return result[i].Time > result[j].Time
+		}
+		return result[i].Command < result[j].Command
+	})
+	return result
+}
+
+//returns source of the last profiled script
+func (p *Profiler) Source() []byte {
+	return p.source.Bytes()
+}
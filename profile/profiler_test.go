@@ -0,0 +1,152 @@
+package profile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	compiler "github.com/gdtrp/brainfuck"
+	"github.com/gdtrp/brainfuck/stack"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+type double struct{}
+
+func (double) Command() stack.Command {
+	return "*"
+}
+func (double) Action() func(ctx *stack.Context) error {
+	return func(ctx *stack.Context) error {
+		ctx.SetCurrentByte(ctx.GetCurrentByte() * 2)
+		return nil
+	}
+}
+
+func TestProfiler_Loops(t *testing.T) {
+	c, _ := compiler.New()
+	p := New(c)
+	var buf bytes.Buffer
+	if err := p.Compile(strings.NewReader("++[>+++\n[>+<-]<-]>>."), nil, &buf); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if buf.Bytes()[0] != 6 {
+		t.Fatalf("wrong result %v", buf.Bytes())
+	}
+	loops := p.Loops()
+	if len(loops) != 2 {
+		t.Fatalf("two loops expected but was %v", loops)
+	}
+	outer, inner := loops[0], loops[1]
+	if outer.Start.Offset != 2 || outer.Entries != 1 || outer.Iterations != 2 {
+		t.Fatalf("wrong outer loop %v", outer)
+	}
+	if inner.Start != (stack.Position{Offset: 8, Line: 2, Column: 1}) || inner.Entries != 2 || inner.Iterations != 6 {
+		t.Fatalf("wrong inner loop %v", inner)
+	}
+	if outer.Instructions <= inner.Instructions {
+		t.Fatalf("outer loop should include instructions of inner loop %v %v", outer, inner)
+	}
+	for _, o := range p.Operations() {
+		if o.Position.Offset == 10 && o.Count != 6 {
+			t.Fatalf("wrong count of inner increment %v", o)
+		}
+	}
+}
+
+func TestProfiler_Custom(t *testing.T) {
+	c, _ := compiler.New(double{})
+	p := New(c)
+	if err := p.Compile(strings.NewReader("+**+*"), nil, nil); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	custom := p.Custom()
+	if len(custom) != 1 || custom[0].Command != "*" || custom[0].Calls != 3 {
+		t.Fatalf("wrong custom statistics %v", custom)
+	}
+}
+
+func TestProfiler_Report(t *testing.T) {
+	c, _ := compiler.New()
+	p := New(c)
+	if err := p.Compile(strings.NewReader("+++\n[-]\n"), nil, nil); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	var buf bytes.Buffer
+	if err := p.WriteReport(&buf, 1); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := "         3    1 | +++\n" +
+		"        10    2 | [-]\n" +
+		"\n" +
+		"loop          entries   iterations   instructions\n" +
+		"2:1                 1            3             10\n"
+	if buf.String() != expected {
+		t.Fatalf("wrong report expected\n%v\nbut was\n%v", expected, buf.String())
+	}
+}
+
+//read fields of protocol buffers message. varint values and length delimited data are returned by field number
+func readFields(t *testing.T, data []byte) map[int][][]byte {
+	fields := make(map[int][][]byte)
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		data = data[n:]
+		switch key & 7 {
+		case 0:
+			_, n = binary.Uvarint(data)
+			fields[int(key>>3)] = append(fields[int(key>>3)], data[:n])
+			data = data[n:]
+		case 2:
+			length, n := binary.Uvarint(data)
+			data = data[n:]
+			fields[int(key>>3)] = append(fields[int(key>>3)], data[:length])
+			data = data[length:]
+		default:
+			t.Fatalf("unexpected wire type %v", key&7)
+		}
+	}
+	return fields
+}
+
+func TestProfiler_Pprof(t *testing.T) {
+	c, _ := compiler.New()
+	p := New(c)
+	if err := p.Compile(strings.NewReader("++[>+++\n[>+<-]<-]"), nil, nil); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	var buf bytes.Buffer
+	if err := p.WritePprof(&buf, "test.bf"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	zipped, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	data, err := ioutil.ReadAll(zipped)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	fields := readFields(t, data)
+	if len(fields[profileSample]) != len(p.Operations()) || len(fields[profileFunction]) != 3 {
+		t.Fatalf("wrong number of samples %v or functions %v", len(fields[profileSample]), len(fields[profileFunction]))
+	}
+	var names []string
+	for _, s := range fields[profileStringTable] {
+		names = append(names, string(s))
+	}
+	if names[0] != "" || !strings.Contains(strings.Join(names, ","), "main,loop at 1:3,loop at 2:1") {
+		t.Fatalf("wrong string table %v", names)
+	}
+	//the deepest sample has frame per loop and one for the whole script
+	deepest := 0
+	for _, s := range fields[profileSample] {
+		locations := readFields(t, s)[sampleLocation][0]
+		if len(locations) > deepest {
+			deepest = len(locations)
+		}
+	}
+	if deepest != 3 {
+		t.Fatalf("three frames expected but was %v", deepest)
+	}
+}
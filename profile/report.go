@@ -0,0 +1,89 @@
+package profile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+/*
+write annotated listing of the last profiled script. every source line is prefixed with number of
+executed operations on the line
+*/
+func (p *Profiler) WriteListing(writer io.Writer) error {
+	counts := make(map[int]int)
+	for _, stats := range p.operations {
+		counts[stats.Position.Line] += stats.Count
+	}
+	lines := bytes.Split(bytes.TrimSuffix(p.source.Bytes(), []byte("\n")), []byte("\n"))
+	for i, line := range lines {
+		count := "-"
+		if c, found := counts[i+1]; found {
+			count = fmt.Sprint(c)
+		}
+		if _, err := fmt.Fprintf(writer, "%10s %4d | %s\n", count, i+1, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+write report of top loops ordered by number of executed instructions. all loops are written when top is not positive
+*/
+func (p *Profiler) WriteLoops(writer io.Writer, top int) error {
+	loops := p.Loops()
+	if top > 0 && top < len(loops) {
+		loops = loops[:top]
+	}
+	if _, err := fmt.Fprintf(writer, "%-10s %10s %12s %14s\n", "loop", "entries", "iterations", "instructions"); err != nil {
+		return err
+	}
+	for _, stats := range loops {
+		if _, err := fmt.Fprintf(writer, "%-10s %10d %12d %14d\n", stats.Start, stats.Entries, stats.Iterations, stats.Instructions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+write time spent in custom operations. nothing is written when no custom operation was executed
+*/
+func (p *Profiler) WriteCustom(writer io.Writer) error {
+	custom := p.Custom()
+	if len(custom) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintf(writer, "%-10s %10s %14s\n", "operation", "calls", "time"); err != nil {
+		return err
+	}
+	for _, stats := range custom {
+		if _, err := fmt.Fprintf(writer, "%-10s %10d %14s\n", stats.Command, stats.Calls, stats.Time); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+write full report: annotated listing, top loops and custom operations
+*/
+func (p *Profiler) WriteReport(writer io.Writer, top int) error {
+	if err := p.WriteListing(writer); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(writer); err != nil {
+		return err
+	}
+	if err := p.WriteLoops(writer, top); err != nil {
+		return err
+	}
+	if len(p.custom) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintln(writer); err != nil {
+		return err
+	}
+	return p.WriteCustom(writer)
+}
@@ -0,0 +1,84 @@
+package compiler
+
+import (
+	"bytes"
+	"github.com/gdtrp/brainfuck/stack"
+	"strings"
+	"testing"
+)
+
+func TestCompiler_SetProfile(t *testing.T) {
+	//prints 1 if cell can hold 256, then reads input and prints it
+	script := overflow + ",."
+	classic, _ := LookupProfile(ProfileClassic)
+	wide, _ := LookupProfile(ProfileWide)
+	portable, _ := LookupProfile(ProfilePortable)
+	for _, test := range []struct {
+		profile  Profile
+		script   string
+		expected []byte
+		error    string
+	}{
+		{classic, script, []byte{0, 0}, ""},
+		{wide, script, []byte{1, 0}, ""},
+		{wide, strings.Repeat(">", 30000) + "+.", []byte{1}, ""},
+		{classic, strings.Repeat(">", 30000) + "+.", nil, "index is out of range"},
+		{portable, "+.,.", []byte{1, 1}, ""},
+		{portable, script, nil, "cell overflow"},
+		{classic, "<", nil, "index is out of range"},
+		{Profile{Name: "centered", CellWidth: 4, Pointer: 2, Cells: 3, EOF: stack.EOFMinusOne}, "<,.>.", []byte{255, 0}, ""},
+		{classic, "#!bf cells=16\n" + script, []byte{1, 1}, ""},
+	} {
+		c, _ := New()
+		if err := c.SetProfile(test.profile); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		var output bytes.Buffer
+		err := c.Compile(strings.NewReader(test.script), strings.NewReader(""), &output)
+		if test.error != "" {
+			if err == nil || err.Error() != test.error {
+				t.Fatalf("%v %.20q: error %q expected but was %v", test.profile.Name, test.script, test.error, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%v %.20q: unexpected error %v", test.profile.Name, test.script, err)
+		}
+		if !bytes.Equal(output.Bytes(), test.expected) {
+			t.Fatalf("%v %.20q: expected %v but was %v", test.profile.Name, test.script, test.expected, output.Bytes())
+		}
+	}
+}
+
+func TestCompiler_SetProfileInvalid(t *testing.T) {
+	c, _ := New()
+	for _, p := range []Profile{
+		{Name: "width", CellWidth: 3, Cells: 10},
+		{Name: "cells", CellWidth: 1},
+		{Name: "pointer", CellWidth: 1, Cells: 10, Pointer: 10},
+	} {
+		if err := c.SetProfile(p); err == nil {
+			t.Fatalf("%v: error expected", p.Name)
+		}
+	}
+}
+
+func TestProfileNames(t *testing.T) {
+	if names := strings.Join(ProfileNames(), " "); names != "classic portable wide" {
+		t.Fatalf("wrong profile names %v", names)
+	}
+}
+
+func TestLookupProfile(t *testing.T) {
+	p, found := LookupProfile(ProfileWide)
+	if !found || p.Name != "wide" || p.CellWidth != 2 {
+		t.Fatalf("wrong profile %v", p)
+	}
+	p.CellWidth = 4
+	if p, _ := LookupProfile(ProfileWide); p.CellWidth != 2 {
+		t.Fatalf("predefined profile should not be changed")
+	}
+	if _, found := LookupProfile("unknown"); found {
+		t.Fatalf("unknown profile should not be found")
+	}
+}
@@ -0,0 +1,92 @@
+package compiler
+
+import (
+	"github.com/gdtrp/brainfuck/stack"
+	"io"
+)
+
+//scanner reads script byte by byte and keeps track of token positions
+type scanner struct {
+	reader io.Reader
+	//position of the next byte
+	position stack.Position
+	token    []byte
+	//maps position of the read script to the original script. can be nil
+	locator locator
+	//checks syntax in strict mode. can be nil
+	checker *strictChecker
+}
+
+//locator is implemented by readers which transform script, for example by preprocessor
+type locator interface {
+	//returns original position of the position in the transformed script
+	locate(stack.Position) stack.Position
+}
+
+//reader of the script which keeps the locator of the original script
+type locatedReader struct {
+	io.Reader
+	locator locator
+}
+
+func (r locatedReader) locate(position stack.Position) stack.Position {
+	return r.locator.locate(position)
+}
+
+/*
+returns reader reading from reader, which reads bytes of the script unchanged, for example io.TeeReader.
+positions of operations are located in the original script if the script is transformed, for example by Preprocess
+*/
+func WrapScript(script io.Reader, reader io.Reader) io.Reader {
+	if l, ok := script.(locator); ok {
+		return locatedReader{Reader: reader, locator: l}
+	}
+	return reader
+}
+
+func newScanner(reader io.Reader) *scanner {
+	l, _ := reader.(locator)
+	return &scanner{
+		reader:   reader,
+		position: stack.Position{Line: 1, Column: 1},
+		token:    make([]byte, 1),
+		locator:  l,
+	}
+}
+
+//read next byte. returns byte value and its position in the script
+func (s *scanner) next() (byte, stack.Position, error) {
+	for {
+		n, err := s.reader.Read(s.token)
+		if n == 0 {
+			if err == nil {
+				continue
+			}
+			if err == io.EOF && s.checker != nil {
+				if err := s.checker.end(); err != nil {
+					return 0, s.position, err
+				}
+			}
+			return 0, s.position, err
+		}
+		position := s.position
+		if s.locator != nil {
+			position = s.locator.locate(position)
+		}
+		s.position.Offset++
+		if s.token[0] == '\n' {
+			s.position.Line++
+			s.position.Column = 1
+		} else {
+			s.position.Column++
+		}
+		if s.checker != nil {
+			if skip, err := s.checker.check(s.token[0], position); err != nil {
+				return 0, position, err
+			} else if skip {
+				continue
+			}
+		}
+		return s.token[0], position, nil
+	}
+}
@@ -0,0 +1,132 @@
+package compiler
+
+import (
+	"errors"
+	"github.com/gdtrp/brainfuck/stack"
+	"io"
+)
+
+//ErrSessionClosed is returned by Session when script is fed after Close
+var ErrSessionClosed = errors.New("session is closed")
+
+//errNoData is returned by session input when all fed script bytes are read
+var errNoData = errors.New("no script data")
+
+/*
+Session executes script fed by chunks. every chunk is executed as far as possible right away, operations of
+loops which are not closed yet are kept until the rest of the loop arrives
+*/
+type Session struct {
+	compiler Compiler
+	context  *stack.Context
+	input    *sessionInput
+	//scanner is created after the script header is read
+	scanner *scanner
+	err     error
+}
+
+//sessionInput returns fed script bytes. it returns io.EOF only after it is closed
+type sessionInput struct {
+	data []byte
+	//number of read bytes. they are kept until the script header is read
+	offset int
+	closed bool
+}
+
+func (i *sessionInput) Read(p []byte) (int, error) {
+	if i.offset == len(i.data) {
+		if i.closed {
+			return 0, io.EOF
+		}
+		return 0, errNoData
+	}
+	n := copy(p, i.data[i.offset:])
+	i.offset += n
+	return n, nil
+}
+
+/*
+create new session. data is read from reader and outgoing bytes are written to writer, memory is configured
+by the compiler profile
+*/
+func NewSession(c Compiler, reader io.Reader, writer io.Writer) *Session {
+	return &Session{
+		compiler: c,
+		context:  c.NewContext(reader, writer),
+		input:    &sessionInput{},
+	}
+}
+
+/*
+feed next chunk of the script and execute it as far as possible. once an error is returned the session is stopped
+and the same error is returned by all further calls
+*/
+func (s *Session) Feed(script []byte) error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.input.closed {
+		return ErrSessionClosed
+	}
+	s.input.data = append(s.input.data, script...)
+	s.err = s.run()
+	return s.err
+}
+
+//feed script chunk. implements io.Writer
+func (s *Session) Write(p []byte) (int, error) {
+	if err := s.Feed(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+/*
+finish the script. the rest of it is executed and brackets balance is validated as by Context.ValidateExecution
+*/
+func (s *Session) Close() error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.input.closed {
+		return ErrSessionClosed
+	}
+	s.input.closed = true
+	s.err = s.run()
+	return s.err
+}
+
+//execute read script bytes. returns nil if all bytes are executed and the script is not closed
+func (s *Session) run() error {
+	if s.scanner == nil {
+		scanner, err := s.compiler.open(s.input, s.context)
+		if err == errNoData {
+			//header is read again when more data arrives
+			s.input.offset = 0
+			return nil
+		} else if err != nil {
+			return err
+		}
+		s.scanner = scanner
+	}
+	err := s.compiler.execute(s.scanner, s.context)
+	s.input.data = s.input.data[s.input.offset:]
+	s.input.offset = 0
+	if err == errNoData {
+		return nil
+	}
+	return err
+}
+
+/*
+returns true if execution waits for more script, for example for the end of an opened loop
+or the end of the script header
+*/
+func (s *Session) Waiting() bool {
+	return s.context.Stack.HasOpenBlock() || s.scanner == nil && len(s.input.data) != 0
+}
+
+//returns execution context with memory and pointer
+func (s *Session) Context() *stack.Context {
+	return s.context
+}
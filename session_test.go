@@ -0,0 +1,114 @@
+package compiler
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSession_Scripts(t *testing.T) {
+	c, _ := New()
+	for _, test := range scripts {
+		for _, size := range []int{1, 7, len(test.script)} {
+			var output bytes.Buffer
+			s := NewSession(c, bytes.NewReader(test.input), &output)
+			script := test.script
+			for len(script) > 0 {
+				n := size
+				if n > len(script) {
+					n = len(script)
+				}
+				if err := s.Feed([]byte(script[:n])); err != nil {
+					t.Fatalf("%v: unexpected error %v", test.name, err)
+				}
+				script = script[n:]
+			}
+			if err := s.Close(); err != nil {
+				t.Fatalf("%v: unexpected error %v", test.name, err)
+			}
+			if !bytes.Equal(output.Bytes(), test.result) {
+				t.Fatalf("%v by %v bytes: expected %v but was %v", test.name, size, test.result, output.Bytes())
+			}
+		}
+	}
+}
+
+func TestSession_Feed(t *testing.T) {
+	c, _ := New()
+	var output bytes.Buffer
+	s := NewSession(c, strings.NewReader(""), &output)
+	for _, test := range []struct {
+		chunk   string
+		output  string
+		waiting bool
+	}{
+		{"+++.", "\x03", false},
+		{"[-.", "\x03\x02", true},
+		{"]", "\x03\x02\x01\x00", false},
+		{"[.", "\x03\x02\x01\x00", true},
+		{"]+.", "\x03\x02\x01\x00\x01", false},
+	} {
+		if _, err := io.WriteString(s, test.chunk); err != nil {
+			t.Fatalf("%q: unexpected error %v", test.chunk, err)
+		}
+		if output.String() != test.output {
+			t.Fatalf("%q: expected output %q but was %q", test.chunk, test.output, output.String())
+		}
+		if s.Waiting() != test.waiting {
+			t.Fatalf("%q: waiting should be %v", test.chunk, test.waiting)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := s.Feed([]byte("+")); err != ErrSessionClosed {
+		t.Fatalf("closed session error expected but was %v", err)
+	}
+}
+
+func TestSession_Header(t *testing.T) {
+	c, _ := New()
+	var output bytes.Buffer
+	s := NewSession(c, strings.NewReader(""), &output)
+	for _, chunk := range []string{"#!b", "f cells=16 dialect=ook\nOok. Ook", ". Ook! Ook! Ook! Ook", "! Ook! Ook."} {
+		if err := s.Feed([]byte(chunk)); err != nil {
+			t.Fatalf("%q: unexpected error %v", chunk, err)
+		}
+		if chunk == "#!b" && !s.Waiting() {
+			t.Fatalf("session should wait for the header")
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if s.Context().CellWidth != 2 || output.String() != "\xff" {
+		t.Fatalf("wrong output %q with cell width %v", output.String(), s.Context().CellWidth)
+	}
+}
+
+func TestSession_Errors(t *testing.T) {
+	c, _ := New()
+	s := NewSession(c, strings.NewReader(""), &bytes.Buffer{})
+	if err := s.Feed([]byte("+[")); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := s.Close(); err == nil || err.Error() != "missing closing brackets" {
+		t.Fatalf("missing brackets error expected but was %v", err)
+	}
+	s = NewSession(c, strings.NewReader(""), &bytes.Buffer{})
+	if err := s.Feed([]byte("+\n<")); err == nil {
+		t.Fatalf("index error expected")
+	}
+	if err := s.Feed([]byte("+")); err == nil || err.Error() != "index is out of range" {
+		t.Fatalf("the same error expected but was %v", err)
+	}
+	c.SetStrict(true, "#")
+	s = NewSession(c, strings.NewReader(""), &bytes.Buffer{})
+	if err := s.Feed([]byte("+ #comment")); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := s.Feed([]byte(" x\n+y")); err == nil || err.Error() != "2:2: unknown character 'y'" {
+		t.Fatalf("syntax error expected but was %v", err)
+	}
+}
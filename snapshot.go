@@ -0,0 +1,89 @@
+package compiler
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gdtrp/brainfuck/stack"
+	"io"
+	"io/ioutil"
+)
+
+//version of the snapshot format
+const SnapshotVersion = 1
+
+//Snapshot contains execution state with the script read position. it can be restored in another process
+type Snapshot struct {
+	Version int `json:"version"`
+	//position of the next script byte to read
+	Script stack.Position `json:"script"`
+	State  stack.State    `json:"state"`
+}
+
+/*
+returns snapshot of the context. execution should be stopped between operations, for example by Context.Suspend
+*/
+func (c Compiler) Snapshot(context *stack.Context) *Snapshot {
+	state := context.State()
+	script := stack.Position{Line: 1, Column: 1}
+	if state.LastAdded != 0 {
+		//operations are single bytes, reading stops right after the last added one
+		script = state.LastPosition
+		script.Offset++
+		script.Column++
+	}
+	return &Snapshot{Version: SnapshotVersion, Script: script, State: state}
+}
+
+/*
+resume execution saved in snapshot using prepared context. script should be read from the beginning,
+bytes before snapshot read position are skipped
+*/
+func (c Compiler) Resume(script io.Reader, snapshot *Snapshot, context *stack.Context) error {
+	if snapshot.Version != SnapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", snapshot.Version)
+	}
+	if err := context.Restore(snapshot.State, c.operation); err != nil {
+		return err
+	}
+	//header is read again for the dialect, its pragmas are already applied to the restored state
+	_, body, position, err := c.readHeader(script)
+	if err != nil {
+		return err
+	}
+	if _, err := io.CopyN(ioutil.Discard, body, int64(snapshot.Script.Offset-position.Offset)); err != nil {
+		return err
+	}
+	if err := context.Resume(); err != nil {
+		return err
+	}
+	s := c.scan(body)
+	if snapshot.Script.Offset > position.Offset {
+		position = snapshot.Script
+	}
+	s.position = position
+	s.locator, _ = script.(locator)
+	return c.execute(s, context)
+}
+
+//write snapshot as JSON
+func (s *Snapshot) Write(writer io.Writer) error {
+	return json.NewEncoder(writer).Encode(s)
+}
+
+//read snapshot written by Snapshot.Write
+func ReadSnapshot(reader io.Reader) (*Snapshot, error) {
+	var result Snapshot
+	if err := json.NewDecoder(reader).Decode(&result); err != nil {
+		return nil, err
+	}
+	if result.Version != SnapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", result.Version)
+	}
+	return &result, nil
+}
+
+//returns operation of the command. used to restore saved state
+func (c Compiler) operation(command stack.Command) (stack.ExternalOperation, bool) {
+	o, found := c.commands[command]
+	return o, found
+}
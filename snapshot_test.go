@@ -0,0 +1,80 @@
+package compiler
+
+import (
+	"bytes"
+	"github.com/gdtrp/brainfuck/stack"
+	"strings"
+	"testing"
+)
+
+//runs script suspending it every n operations and resuming it from serialized snapshot in a new context
+func runWithSnapshots(t *testing.T, c Compiler, script string, input []byte, every int) ([]byte, int) {
+	reader := bytes.NewReader(input)
+	var output bytes.Buffer
+	var snapshot *Snapshot
+	snapshots := 0
+	for {
+		ctx := stack.NewContextWithMemorySize(reader, &output, 1024)
+		steps := 0
+		ctx.AfterAction = func(ctx *stack.Context) error {
+			if steps++; steps%every == 0 {
+				ctx.Suspend()
+			}
+			return nil
+		}
+		var err error
+		if snapshot == nil {
+			err = c.Run(strings.NewReader(script), ctx)
+		} else {
+			err = c.Resume(strings.NewReader(script), snapshot, ctx)
+		}
+		if err == nil {
+			return output.Bytes(), snapshots
+		}
+		if err != stack.ErrSuspended {
+			t.Fatalf("unexpected error %v", err)
+		}
+		var buf bytes.Buffer
+		if err := c.Snapshot(ctx).Write(&buf); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if snapshot, err = ReadSnapshot(&buf); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		snapshots++
+	}
+}
+
+func TestCompiler_Resume(t *testing.T) {
+	c, _ := New()
+	for _, test := range scripts {
+		for _, every := range []int{1, 13, 997} {
+			if every < 997 && len(test.script) > 200 {
+				//long running scripts are suspended less often
+				continue
+			}
+			result, snapshots := runWithSnapshots(t, c, test.script, test.input, every)
+			if every == 1 && snapshots == 0 {
+				t.Fatalf("%v: execution should be suspended", test.name)
+			}
+			if !bytes.Equal(result, test.result) {
+				t.Fatalf("%v with snapshot every %v operations: expected %v but was %v", test.name, every, test.result, result)
+			}
+		}
+	}
+}
+
+func TestCompiler_ResumeBlocks(t *testing.T) {
+	c, _ := New(blocks...)
+	for _, test := range blockScripts {
+		if result, _ := runWithSnapshots(t, c, test.script, nil, 1); !bytes.Equal(result, test.result) {
+			t.Fatalf("%v: expected %v but was %v", test.script, test.result, result)
+		}
+	}
+}
+
+func TestReadSnapshot_Version(t *testing.T) {
+	if _, err := ReadSnapshot(strings.NewReader(`{"version":2}`)); err == nil {
+		t.Fatalf("unsupported version error expected")
+	}
+}
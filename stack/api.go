@@ -1,5 +1,7 @@
 package stack
 
+import "fmt"
+
 //API interface to create custom operations
 type ExternalOperation interface {
 	//token value
@@ -8,3 +10,45 @@ type ExternalOperation interface {
 	Action() func(*Context) error
 }
 type Command string
+
+/*
+BlockOperation is operation which changes the stack when it is added to it. it is used to create control flow:
+OnAdd calls Stack.OpenBlock to start a block and AfterAdd calls Stack.CloseBlock to finish it.
+actions of operations inside the block can skip or repeat it by Stack.SkipBlock and Stack.RepeatBlock.
+script is not read ahead, so skipped block which is not read fully is added without execution until it is closed
+*/
+type BlockOperation interface {
+	ExternalOperation
+	//action will be executed before adding operation to stack. Can be nil
+	OnAdd() func(*Context) error
+	//action will be executed after adding operation to stack. Can be nil
+	AfterAdd() func(*Context) error
+}
+
+//Position of token in the script
+type Position struct {
+	//name of included file. empty for the script itself
+	File string `json:"file,omitempty"`
+	//byte offset starting from 0
+	Offset int `json:"offset"`
+	//line number starting from 1
+	Line int `json:"line"`
+	//column number starting from 1
+	Column int `json:"column"`
+}
+
+func (p Position) String() string {
+	if p.File != "" {
+		return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+//returns operation with the same command which executes provided action. actions on add are kept for system commands
+func WithAction(o ExternalOperation, action func(*Context) error) ExternalOperation {
+	result := operation{token: o.Command(), action: action}
+	if internal, ok := o.(BlockOperation); ok {
+		result.onAdd, result.afterAdd = internal.OnAdd(), internal.AfterAdd()
+	}
+	return result
+}
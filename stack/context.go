@@ -2,25 +2,73 @@ package stack
 
 import (
 	"errors"
+	"fmt"
 	"io"
+	"sync/atomic"
+)
+
+//ErrSuspended is returned when execution is stopped by Suspend. execution can be continued by Resume
+var ErrSuspended = errors.New("execution is suspended")
+
+//EOFBehavior defines value stored by input operation when input is finished
+type EOFBehavior int
+
+const (
+	//cell is not changed
+	EOFUnchanged EOFBehavior = iota
+	//zero is stored
+	EOFZero
+	//all bits of the cell are set
+	EOFMinusOne
+)
+
+//OverflowBehavior defines result of incrementing the maximal cell value and decrementing zero
+type OverflowBehavior int
+
+const (
+	//value wraps around
+	OverflowWrap OverflowBehavior = iota
+	//execution stops with error
+	OverflowError
 )
 
 //Context struct contains all execution data
 type Context struct {
-	//current memory
+	//current memory. cells wider than one byte are stored in little endian order
 	Memory []byte
 	//current memory cell index
 	CurrentIdx int
+	//number of bytes in memory cell: 1, 2 or 4. zero means one byte
+	CellWidth int
+	//value stored by input operation at the end of input
+	EOF EOFBehavior
+	//memory grows when pointer moves past its end
+	Grow bool
+	//result of cell overflow
+	Overflow OverflowBehavior
 	//output writer
 	Writer io.Writer
 	//input reader
 	Reader io.Reader
 	//stack struct responsible for command execution order
 	Stack *Stack
+	//optional function called before every operation popped from the stack. returned error stops execution
+	BeforeAction func(*Context) error
+	//optional function called after every successfully executed operation. returned error stops execution
+	AfterAction func(*Context) error
+	//optional value of the caller, for example request handled by custom operations. not used by the context
+	Value interface{}
+	//set by Suspend, checked before every operation popped from the stack
+	suspended int32
+	//registered observers. notifications are skipped if empty
+	observers []Observer
 }
 
 const defaultMemorySize = 65536
 
+//MaxMemorySize is the maximal number of memory bytes allocated by Configure and by growing memory
+const MaxMemorySize = 64 << 20
+
 func NewContext(reader io.Reader, writer io.Writer) *Context {
 	return NewContextWithMemorySize(reader, writer, defaultMemorySize)
 }
@@ -28,6 +76,7 @@ func NewContextWithMemorySize(reader io.Reader, writer io.Writer, size int) *Con
 	return &Context{
 		Memory:     make([]byte, size),
 		CurrentIdx: 0,
+		CellWidth:  1,
 		Writer:     writer,
 		Reader:     reader,
 		Stack:      &Stack{},
@@ -39,30 +88,97 @@ func (c *Context) GetIndex() int {
 	return c.CurrentIdx
 }
 
-//sets current memory cell index
+//sets current memory cell index. memory grows up to MaxMemorySize if it is enabled and index is past its end
 func (c *Context) SetIndex(index int) error {
-	if err := validate(index, c.Memory); err != nil {
+	if limit := MaxMemorySize / c.width(); c.Grow && index >= c.Cells() && index < limit {
+		cells := c.Cells() * 2
+		if cells <= index {
+			cells = index + 1
+		}
+		if cells > limit {
+			cells = limit
+		}
+		c.Memory = append(c.Memory, make([]byte, (cells-c.Cells())*c.width())...)
+	}
+	if err := c.validate(index); err != nil {
 		return err
 	}
 	c.CurrentIdx = index
 	return nil
 }
 
-//returns byte value of memory cell index
+//returns number of memory cells
+func (c *Context) Cells() int {
+	return len(c.Memory) / c.width()
+}
+
+//returns maximal value of the cell
+func (c *Context) MaxCell() uint32 {
+	return ^uint32(0) >> (32 - 8*uint(c.width()))
+}
+
+func (c *Context) width() int {
+	if c.CellWidth > 1 {
+		return c.CellWidth
+	}
+	return 1
+}
+
+/*
+change cell width and number of memory cells. memory is cleared and pointer is moved to the first cell.
+memory size is limited by MaxMemorySize
+*/
+func (c *Context) Configure(width int, cells int) error {
+	if width != 1 && width != 2 && width != 4 {
+		return fmt.Errorf("unsupported cell width %d", width)
+	}
+	if cells <= 0 {
+		return errors.New("number of cells should be positive")
+	}
+	if cells > MaxMemorySize/width {
+		return fmt.Errorf("memory size is limited to %d bytes", MaxMemorySize)
+	}
+	c.CellWidth = width
+	c.Memory = make([]byte, width*cells)
+	c.CurrentIdx = 0
+	return nil
+}
+
+//returns byte value of memory cell index. the lowest byte is returned for wider cells
 func (c *Context) GetCurrentByte() byte {
 	b, _ := c.GetByte(c.CurrentIdx)
 	return b
 }
 
-//returns byte value of provided cell index
+//returns byte value of provided cell index. the lowest byte is returned for wider cells
 func (c *Context) GetByte(index int) (byte, error) {
-	if err := validate(index, c.Memory); err != nil {
+	value, err := c.GetCell(index)
+	return byte(value), err
+}
+
+//returns value of the current cell
+func (c *Context) GetCurrentCell() uint32 {
+	value, _ := c.GetCell(c.CurrentIdx)
+	return value
+}
+
+//returns value of provided cell index
+func (c *Context) GetCell(index int) (uint32, error) {
+	if err := c.validate(index); err != nil {
 		return 0, err
 	}
-	return c.Memory[index], nil
+	if c.width() == 1 {
+		return uint32(c.Memory[index]), nil
+	}
+	var value uint32
+	for i, b := range c.Memory[index*c.width() : (index+1)*c.width()] {
+		value |= uint32(b) << (8 * uint(i))
+	}
+	return value, nil
 }
-func validate(index int, memory []byte) error {
-	if index >= len(memory) || index < 0 {
+
+func (c *Context) validate(index int) error {
+	if index >= c.Cells() || index < 0 {
 		return errors.New("index is out of range")
 	}
 	return nil
@@ -75,40 +191,124 @@ func (c *Context) SetCurrentByte(b byte) error{
 
 //set byte value of provided cell index
 func (c *Context) SetByte(index int, b byte) error {
-	if err := validate(index, c.Memory); err != nil {
+	return c.SetCell(index, uint32(b))
+}
+
+//sets value of the current cell
+func (c *Context) SetCurrentCell(value uint32) error {
+	return c.SetCell(c.CurrentIdx, value)
+}
+
+//sets value of provided cell index. value is truncated to the cell width
+func (c *Context) SetCell(index int, value uint32) error {
+	if err := c.validate(index); err != nil {
 		return err
 	}
-	c.Memory[index] = b
+	if c.width() == 1 {
+		c.Memory[index] = byte(value)
+		return nil
+	}
+	cell := c.Memory[index*c.width() : (index+1)*c.width()]
+	for i := range cell {
+		cell[i] = byte(value >> (8 * uint(i)))
+	}
 	return nil
 }
 
 //execute next operation from stack
 func (c *Context) Execute(operation ExternalOperation) error {
+	return c.ExecuteAt(operation, Position{})
+}
 
-	internal, ok := operation.(internalOperation)
+//execute next operation from stack. position of operation token in the script is stored with the operation
+func (c *Context) ExecuteAt(operation ExternalOperation, position Position) error {
+	if err := c.Add(operation, position); err != nil {
+		return err
+	}
+	return c.drain()
+}
+
+//add operation to the stack without executing it. position of operation token in the script is stored with the operation
+func (c *Context) Add(operation ExternalOperation, position Position) error {
+	internal, ok := operation.(BlockOperation)
 	if ok && internal.OnAdd() != nil {
 		if err := internal.OnAdd()(c); err != nil {
-			return err
+			return c.fail(err)
 		}
 	}
-	c.Stack.push(operation)
+	c.Stack.push(operation, position)
 
 	if ok && internal.AfterAdd() != nil {
 		if err := internal.AfterAdd()(c); err != nil {
-			return err
+			return c.fail(err)
+		}
+	}
+	return nil
+}
+
+//returns true if the stack has operation ready for execution
+func (c *Context) HasNext() bool {
+	return c.Stack.Next() != nil
+}
+
+//execute single operation from the stack. does nothing if no operation is ready for execution
+func (c *Context) Step() error {
+	if !c.HasNext() {
+		return nil
+	}
+	previous := c.Stack.current
+	op := c.Stack.pop()
+	if c.BeforeAction != nil {
+		if err := c.BeforeAction(c); err != nil {
+			return c.fail(err)
 		}
 	}
-	if !c.Stack.isSkipExecution() {
-		for c.Stack.hasNext() {
-			op := c.Stack.pop()
-			if err := op.Action()(c); err != nil {
-				return err
-			}
+	if len(c.observers) != 0 {
+		c.beforeOperation(previous)
+	}
+	if err := op.Action()(c); err != nil {
+		return c.fail(err)
+	}
+	if c.AfterAction != nil {
+		if err := c.AfterAction(c); err != nil {
+			return c.fail(err)
 		}
 	}
+	if len(c.observers) != 0 {
+		c.afterOperation()
+	}
 	return nil
 }
 
+//execute operations remaining in the stack unless loop is skipped
+func (c *Context) drain() error {
+	for c.HasNext() {
+		if atomic.CompareAndSwapInt32(&c.suspended, 1, 0) {
+			return ErrSuspended
+		}
+		if err := c.Step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+suspend execution before the next operation popped from the stack. execution returns ErrSuspended
+and the state stays consistent. safe to call from another goroutine
+*/
+func (c *Context) Suspend() {
+	atomic.StoreInt32(&c.suspended, 1)
+}
+
+//continue execution of operations remaining in the stack after suspension or restore
+func (c *Context) Resume() error {
+	return c.drain()
+}
+
 func (c *Context) ValidateExecution() error {
-	return c.Stack.validateExecution()
+	if err := c.Stack.validateExecution(); err != nil {
+		return c.fail(err)
+	}
+	return nil
 }
@@ -129,3 +129,97 @@ func TestExecute(t *testing.T) {
 		t.Errorf("error expected")
 	}
 }
+
+func TestSuspend(t *testing.T) {
+	ctx := NewContextWithMemorySize(nil, bytes.NewBuffer(nil), 5)
+	for _, token := range []byte("+++[>+<-") {
+		if err := ctx.Execute(operationOf(Command(token))); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	ctx.Suspend()
+	if err := ctx.Execute(endLoop); err != ErrSuspended {
+		t.Fatalf("suspended error expected but was %v", err)
+	}
+	restored := NewContextWithMemorySize(nil, bytes.NewBuffer(nil), 5)
+	if err := restored.Restore(ctx.State(), func(command Command) (ExternalOperation, bool) {
+		return operationOf(command), true
+	}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := restored.Resume(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if restored.Memory[0] != 0 || restored.Memory[1] != 3 {
+		t.Fatalf("wrong memory %v", restored.Memory)
+	}
+	if err := restored.ValidateExecution(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func operationOf(command Command) ExternalOperation {
+	for _, o := range GetDefaultOperations() {
+		if o.Command() == command {
+			return o
+		}
+	}
+	return nil
+}
+
+func TestCells(t *testing.T) {
+	ctx := NewContextWithMemorySize(bytes.NewReader(nil), &bytes.Buffer{}, 4)
+	if err := ctx.Configure(2, 3); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := ctx.SetCell(1, 0x12345); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !bytes.Equal(ctx.Memory, []byte{0, 0, 0x45, 0x23, 0, 0}) {
+		t.Fatalf("wrong memory %v", ctx.Memory)
+	}
+	if b, _ := ctx.GetByte(1); b != 0x45 {
+		t.Fatalf("wrong byte %v", b)
+	}
+	if err := ctx.SetIndex(3); err == nil {
+		t.Fatalf("index out of range error expected")
+	}
+	ctx.Grow = true
+	if err := ctx.SetIndex(7); err != nil || ctx.Cells() != 8 || len(ctx.Memory) != 16 {
+		t.Fatalf("memory should grow to 8 cells but was %v, error %v", ctx.Cells(), err)
+	}
+	if err := ctx.Configure(3, 1); err == nil {
+		t.Fatalf("unsupported width error expected")
+	}
+}
+
+func TestEOF(t *testing.T) {
+	for _, test := range []struct {
+		eof      EOFBehavior
+		expected uint32
+	}{
+		{EOFUnchanged, 1}, {EOFZero, 0}, {EOFMinusOne, 0xffff},
+	} {
+		ctx := NewContext(bytes.NewReader(nil), &bytes.Buffer{})
+		ctx.Configure(2, 1)
+		ctx.EOF = test.eof
+		ctx.SetCurrentCell(1)
+		if err := ctx.Execute(input); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if ctx.GetCurrentCell() != test.expected {
+			t.Fatalf("eof %v: expected %v but was %v", test.eof, test.expected, ctx.GetCurrentCell())
+		}
+	}
+}
+
+func TestMaxMemorySize(t *testing.T) {
+	ctx := NewContextWithMemorySize(bytes.NewReader(nil), &bytes.Buffer{}, 4)
+	if err := ctx.Configure(4, MaxMemorySize/4+1); err == nil {
+		t.Fatalf("memory limit error expected")
+	}
+	ctx.Grow = true
+	if err := ctx.SetIndex(MaxMemorySize); err == nil || len(ctx.Memory) != 4 {
+		t.Fatalf("memory should not grow past the limit, %v bytes", len(ctx.Memory))
+	}
+}
@@ -0,0 +1,98 @@
+package stack
+
+/*
+Observer is notified about execution events of the context. it cannot change execution,
+BeforeAction and AfterAction of the context can be used to stop it.
+NopObserver can be embedded to implement only needed methods
+*/
+type Observer interface {
+	//called before operation popped from the stack is executed
+	BeforeOperation(ctx *Context, element OperationalElement)
+	//called after operation is executed successfully
+	AfterOperation(ctx *Context, element OperationalElement)
+	//loop events are called before the operation which starts the loop is executed.
+	//enter and skip are called when execution arrives at the loop, iterate and exit when loop condition is checked again
+	LoopEnter(ctx *Context, start OperationalElement)
+	LoopIterate(ctx *Context, start OperationalElement)
+	LoopExit(ctx *Context, start OperationalElement)
+	LoopSkip(ctx *Context, start OperationalElement)
+	//called after bytes are read by input operation
+	InputRead(ctx *Context, data []byte)
+	//called after bytes are written by output operation
+	OutputWrite(ctx *Context, data []byte)
+	//called with error which stops execution
+	Error(ctx *Context, err error)
+}
+
+//NopObserver implements Observer with methods doing nothing
+type NopObserver struct{}
+
+func (NopObserver) BeforeOperation(*Context, OperationalElement) {}
+func (NopObserver) AfterOperation(*Context, OperationalElement)  {}
+func (NopObserver) LoopEnter(*Context, OperationalElement)       {}
+func (NopObserver) LoopIterate(*Context, OperationalElement)     {}
+func (NopObserver) LoopExit(*Context, OperationalElement)        {}
+func (NopObserver) LoopSkip(*Context, OperationalElement)        {}
+func (NopObserver) InputRead(*Context, []byte)                   {}
+func (NopObserver) OutputWrite(*Context, []byte)                 {}
+func (NopObserver) Error(*Context, error)                        {}
+
+//register observer. observers are notified in order of registration
+func (c *Context) Observe(observer Observer) {
+	c.observers = append(c.observers, observer)
+}
+
+//notify observers before operation. previous is the element executed before it
+func (c *Context) beforeOperation(previous LinkedElement) {
+	current := c.Stack.Current()
+	for _, o := range c.observers {
+		o.BeforeOperation(c, current)
+	}
+	if current.Operation().Command() != startLoop.token {
+		return
+	}
+	//condition is checked again if the end of the same loop was executed right before
+	check := false
+	if p, ok := previous.(*OperationContainer); ok {
+		check = p.operation.Command() == endLoop.token && p.loop == current.CurrentLoop()
+	}
+	event := Observer.LoopEnter
+	switch {
+	case check && c.GetCurrentCell() == 0:
+		event = Observer.LoopExit
+	case check:
+		event = Observer.LoopIterate
+	case c.GetCurrentCell() == 0:
+		event = Observer.LoopSkip
+	}
+	for _, o := range c.observers {
+		event(o, c, current)
+	}
+}
+
+func (c *Context) afterOperation() {
+	current := c.Stack.Current()
+	for _, o := range c.observers {
+		o.AfterOperation(c, current)
+	}
+}
+
+func (c *Context) inputRead(data []byte) {
+	for _, o := range c.observers {
+		o.InputRead(c, data)
+	}
+}
+
+func (c *Context) outputWrite(data []byte) {
+	for _, o := range c.observers {
+		o.OutputWrite(c, data)
+	}
+}
+
+//notify observers about error and return it
+func (c *Context) fail(err error) error {
+	for _, o := range c.observers {
+		o.Error(c, err)
+	}
+	return err
+}
@@ -0,0 +1,74 @@
+package stack
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+//observer which records events with positions
+type recordingObserver struct {
+	NopObserver
+	events []string
+}
+
+func (r *recordingObserver) record(event string, element OperationalElement) {
+	r.events = append(r.events, fmt.Sprintf("%s %d", event, element.Position().Offset))
+}
+func (r *recordingObserver) LoopEnter(_ *Context, start OperationalElement) {
+	r.record("enter", start)
+}
+func (r *recordingObserver) LoopIterate(_ *Context, start OperationalElement) {
+	r.record("iterate", start)
+}
+func (r *recordingObserver) LoopExit(_ *Context, start OperationalElement) {
+	r.record("exit", start)
+}
+func (r *recordingObserver) LoopSkip(_ *Context, start OperationalElement) {
+	r.record("skip", start)
+}
+func (r *recordingObserver) InputRead(_ *Context, data []byte) {
+	r.events = append(r.events, fmt.Sprintf("read %v", data))
+}
+func (r *recordingObserver) OutputWrite(_ *Context, data []byte) {
+	r.events = append(r.events, fmt.Sprintf("write %v", data))
+}
+func (r *recordingObserver) Error(_ *Context, err error) {
+	r.events = append(r.events, "error "+err.Error())
+}
+
+func TestObserver(t *testing.T) {
+	ctx := NewContextWithMemorySize(bytes.NewReader([]byte{2}), bytes.NewBuffer(nil), 5)
+	observer := &recordingObserver{}
+	ctx.Observe(observer)
+	operations := 0
+	ctx.Observe(&countingObserver{count: &operations})
+	for i, token := range []byte(",[->+<]>[-[-]][+].]") {
+		if err := ctx.ExecuteAt(operationOf(Command(token)), Position{Offset: i}); err != nil {
+			if i != 18 {
+				t.Fatalf("unexpected error %v", err)
+			}
+		}
+	}
+	expected := []string{
+		"read [2]", "enter 1", "iterate 1", "exit 1", "enter 8", "enter 10", "exit 10", "exit 8", "skip 14", "write [0]",
+		"error missing start loop",
+	}
+	if strings.Join(observer.events, ", ") != strings.Join(expected, ", ") {
+		t.Fatalf("wrong events expected\n%v\nbut was\n%v", strings.Join(expected, ", "), strings.Join(observer.events, ", "))
+	}
+	if operations != 25 {
+		t.Fatalf("wrong number of operations %v", operations)
+	}
+}
+
+//observer which counts executed operations
+type countingObserver struct {
+	NopObserver
+	count *int
+}
+
+func (c *countingObserver) AfterOperation(*Context, OperationalElement) {
+	*c.count++
+}
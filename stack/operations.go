@@ -1,18 +1,10 @@
 package stack
 
 import (
+	"errors"
 	"io"
 )
 
-//internal interface for system commands
-type internalOperation interface {
-	ExternalOperation
-	//action will be executed before adding operation to stack. Can be nil
-	OnAdd() func(*Context) error
-	//action will be executed after adding operation to stack. Can be nil
-	AfterAdd() func(*Context) error
-}
-
 type operation struct {
 	token    Command
 	action   func(*Context) error
@@ -43,7 +35,11 @@ func GetDefaultOperations() []operation {
 var incr = operation{
 	token: "+",
 	action: func(ctx *Context) error {
-		return ctx.SetCurrentByte(ctx.GetCurrentByte() + 1)
+		value := ctx.GetCurrentCell()
+		if value == ctx.MaxCell() && ctx.Overflow == OverflowError {
+			return errors.New("cell overflow")
+		}
+		return ctx.SetCurrentCell(value + 1)
 	},
 }
 
@@ -51,7 +47,11 @@ var incr = operation{
 var decr = operation{
 	token: "-",
 	action: func(ctx *Context) error {
-		return ctx.SetCurrentByte(ctx.GetCurrentByte() - 1)
+		value := ctx.GetCurrentCell()
+		if value == 0 && ctx.Overflow == OverflowError {
+			return errors.New("cell underflow")
+		}
+		return ctx.SetCurrentCell(value - 1)
 	},
 }
 
@@ -76,8 +76,14 @@ var output = operation{
 	token: ".",
 
 	action: func(ctx *Context) error {
-		_, err := ctx.Writer.Write([]byte{ctx.GetCurrentByte()})
-		return err
+		data := []byte{ctx.GetCurrentByte()}
+		if _, err := ctx.Writer.Write(data); err != nil {
+			return err
+		}
+		if len(ctx.observers) != 0 {
+			ctx.outputWrite(data)
+		}
+		return nil
 	},
 }
 
@@ -86,10 +92,20 @@ var input = operation{
 	token: ",",
 	action: func(ctx *Context) error {
 		b := make([]byte, 1)
-		if _, err := ctx.Reader.Read(b); err == nil {
+		//byte returned together with io.EOF is read as well
+		if _, err := io.ReadFull(ctx.Reader, b); err == nil {
+			if len(ctx.observers) != 0 {
+				ctx.inputRead(b)
+			}
 			return ctx.SetCurrentByte(b[0])
 		} else {
 			if err == io.EOF {
+				switch ctx.EOF {
+				case EOFZero:
+					return ctx.SetCurrentCell(0)
+				case EOFMinusOne:
+					return ctx.SetCurrentCell(^uint32(0))
+				}
 				return nil
 			}
 			return err
@@ -102,12 +118,12 @@ var startLoop = operation{
 	token: "[",
 
 	onAdd: func(ctx *Context) error {
-		ctx.Stack.pushLoop()
+		ctx.Stack.OpenBlock()
 		return nil
 	},
 	action: func(ctx *Context) error {
-		if ctx.GetCurrentByte() == 0 {
-			ctx.Stack.breakLoop()
+		if ctx.GetCurrentCell() == 0 {
+			return ctx.Stack.SkipBlock()
 		}
 		return nil
 	},
@@ -117,10 +133,9 @@ var startLoop = operation{
 var endLoop = operation{
 	token: "]",
 	afterAdd: func(ctx *Context) error {
-		return ctx.Stack.closeLoop()
+		return ctx.Stack.CloseBlock()
 	},
 	action: func(ctx *Context) error {
-		ctx.Stack.endLoop()
-		return nil
+		return ctx.Stack.RepeatBlock()
 	},
 }
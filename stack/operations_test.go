@@ -3,6 +3,7 @@ package stack
 import (
 	"bytes"
 	"testing"
+	"testing/iotest"
 )
 
 func TestIncrOperation(t *testing.T) {
@@ -150,3 +151,34 @@ func TestSetPointerOperation(t *testing.T) {
 		t.Error("arrays aren't equal")
 	}
 }
+
+func TestOverflow(t *testing.T) {
+	context := Context{
+		Memory:     []byte{255, 0, 255, 255},
+		CellWidth:  2,
+		CurrentIdx: 1,
+		Overflow:   OverflowError,
+	}
+	if err := incr.action(&context); err == nil || err.Error() != "cell overflow" {
+		t.Errorf("overflow error expected but was %v", err)
+	}
+	context.CurrentIdx = 0
+	if err := incr.action(&context); err != nil || context.GetCurrentCell() != 256 {
+		t.Errorf("cell should be incremented to 256 but was %v, error %v", context.GetCurrentCell(), err)
+	}
+	context.Memory[0], context.Memory[1] = 0, 0
+	if err := decr.action(&context); err == nil || err.Error() != "cell underflow" {
+		t.Errorf("underflow error expected but was %v", err)
+	}
+	context.Overflow = OverflowWrap
+	if err := decr.action(&context); err != nil || context.GetCurrentCell() != 0xffff {
+		t.Errorf("cell should wrap around but was %v, error %v", context.GetCurrentCell(), err)
+	}
+}
+
+func TestInputOperation_DataWithEOF(t *testing.T) {
+	context := NewContext(iotest.DataErrReader(bytes.NewReader([]byte{7})), &bytes.Buffer{})
+	if err := input.action(context); err != nil || context.GetCurrentByte() != 7 {
+		t.Errorf("byte returned with EOF should be read but was %v, error %v", context.GetCurrentByte(), err)
+	}
+}
@@ -22,6 +22,8 @@ type LinkedElement interface {
 type OperationalElement interface {
 	LinkedElement
 	Operation() ExternalOperation
+	//position of operation token in the script
+	Position() Position
 }
 
 type LoopElement interface {
@@ -42,6 +44,11 @@ type Stack struct {
 	currentLoop LoopElement
 	//link to last added element to stack.
 	lastAdded LinkedElement
+	//position of last added operation
+	lastPosition Position
+	//next and current elements before the last pop. used to execute the popped operation again
+	popped   LinkedElement
+	previous LinkedElement
 }
 
 //High level struct contains links to previous and next elements in execution order
@@ -55,6 +62,8 @@ type OperationContainer struct {
 	operation ExternalOperation
 	//Current loop link
 	loop LoopElement
+	//position of operation token in the script
+	position Position
 }
 
 //LoopContainer struct
@@ -62,6 +71,8 @@ type LoopContainer struct {
 	Link
 	//Link to first loop element
 	firstLoopElement OperationalElement
+	//Link to upper level loop
+	parent LoopElement
 }
 
 func (c *OperationContainer) Loop() LoopElement {
@@ -76,6 +87,9 @@ func (c *LoopContainer) CurrentLoop() LoopElement {
 func (c *OperationContainer) Operation() ExternalOperation {
 	return c.operation
 }
+func (c *OperationContainer) Position() Position {
+	return c.position
+}
 
 func (c *OperationContainer) CurrentOperation() OperationalElement {
 	return c
@@ -84,7 +98,10 @@ func (c *OperationContainer) CurrentOperation() OperationalElement {
 func (c *OperationContainer) ConfigureLink(stack *Stack) {
 	c.linkWithLoop(stack.currentLoop)
 	linkPrevious(stack.lastAdded, c)
-	stack.nextElement = c
+	if stack.nextElement == nil {
+		//operations added before are executed first
+		stack.nextElement = c
+	}
 	stack.lastAdded = c
 }
 
@@ -102,16 +119,22 @@ func linkPrevious(element LinkedElement, c LinkedElement) {
 }
 func (c *LoopContainer) ConfigureLink(stack *Stack) {
 	linkPrevious(stack.lastAdded, c)
+	c.parent = stack.currentLoop
 	stack.currentLoop = c
 	stack.lastAdded = nil
 }
 
 func (c *LoopContainer) setFirstElement(element OperationalElement) {
-	c.firstLoopElement = element
+	if c.firstLoopElement == nil {
+		c.firstLoopElement = element
+	}
 }
 func (c *LoopContainer) CurrentOperation() OperationalElement {
 	return c.firstLoopElement
 }
+func (c *LoopContainer) GetPreviousLoop() LoopElement {
+	return c.parent
+}
 func (c *Link) GetPreviousLoop() LoopElement {
 	prev := c.Previous()
 	if prev != nil {
@@ -145,17 +168,15 @@ func (c *Link) HasMoreElements() bool {
 }
 
 //push operation to stack
-func (s *Stack) push(operation ExternalOperation) {
-	newOp := &OperationContainer{operation: operation}
+func (s *Stack) push(operation ExternalOperation, position Position) {
+	newOp := &OperationContainer{operation: operation, position: position}
 	newOp.ConfigureLink(s)
+	s.lastPosition = position
 }
 
-//check if has next element in stack
-func (s *Stack) hasNext() bool {
-	return s.nextElement != nil
-}
 //retrieve element from stack and set next
 func (s *Stack) pop() ExternalOperation {
+	s.popped, s.previous = s.nextElement, s.current
 	current := s.nextElement.CurrentOperation()
 	result := current.Operation()
 	s.current = current
@@ -163,43 +184,107 @@ func (s *Stack) pop() ExternalOperation {
 	return result
 }
 
+/*
+move back before the last popped operation, so it is executed again by the next step. should be called only
+when the operation failed without changing the context, for example because its input is not available yet
+*/
+func (s *Stack) Retry() {
+	if s.popped != nil {
+		s.nextElement, s.current = s.popped, s.previous
+		s.popped, s.previous = nil, nil
+	}
+}
 
-//add loop element to stack and set it current
-func (s *Stack) pushLoop() {
+/*
+open block. operations added after it belong to the block until it is closed.
+should be called by OnAdd of block operation, the operation itself becomes the first element of the block
+*/
+func (s *Stack) OpenBlock() {
 	newOp := &LoopContainer{}
 	newOp.ConfigureLink(s)
 }
 
-//mark current loop as finished. returns error if initLoop method wasn't called
-func (s *Stack) closeLoop() error {
+/*
+close the block which is currently read. should be called by AfterAdd of block operation,
+the operation itself becomes the last element of the block. returns error if no block is open
+*/
+func (s *Stack) CloseBlock() error {
 	if s.currentLoop == nil {
 		return errors.New("missing start loop")
 	}
 	s.lastAdded = s.currentLoop
 	if s.skip == s.currentLoop {
+		//skipped loop is fully read, execution continues with the element added after it
 		s.skip = nil
+		s.nextElement = nil
 	}
 	s.currentLoop = s.currentLoop.GetPreviousLoop()
 	return nil
 }
 
-//rewind loop to beginning
-func (s *Stack) endLoop() {
+//returns element of the operation which is currently executed. returns nil if nothing is executed yet
+func (s *Stack) Current() OperationalElement {
+	if s.current == nil {
+		return nil
+	}
+	return s.current.CurrentOperation()
+}
+
+//returns element of the operation which will be executed next. returns nil if no operation is ready for execution
+func (s *Stack) Next() OperationalElement {
+	if s.nextElement == nil || s.isSkipExecution() {
+		return nil
+	}
+	return s.nextElement.CurrentOperation()
+}
+
+//returns number of loops enclosing currently executed operation
+func (s *Stack) Depth() int {
+	depth := 0
+	if s.current == nil {
+		return depth
+	}
+	for loop := s.current.CurrentLoop(); loop != nil; loop = loop.GetPreviousLoop() {
+		depth++
+	}
+	return depth
+}
+
+//repeat the block of currently executed operation starting from its first operation. returns error outside of block
+func (s *Stack) RepeatBlock() error {
+	if s.current == nil || s.current.CurrentLoop() == nil {
+		return errors.New("operation is not in a block")
+	}
 	s.nextElement = s.current.RewindToStart()
+	return nil
+}
+//returns true if a block is opened but not closed yet. the block can't be finished until the rest of it is added
+func (s *Stack) HasOpenBlock() bool {
+	return s.currentLoop != nil
 }
+
 //specific case for loops which needs to be added but without execution (covers excludes look-ahead requirement)
 func (s *Stack) isSkipExecution() bool {
 	return s.skip != nil
 }
 
-//ends loop execution and jump to next element.
-//if next element is empty that means that the loop execution needs to be skipped,
-//because current loop is not read fully (covers excludes look-ahead requirement)
-func (s *Stack) breakLoop() {
-	s.nextElement = s.current.CurrentLoop().Next()
-	if !s.current.HasMoreElements() {
-		s.skip = s.current.CurrentLoop()
+/*
+skip the rest of the block of currently executed operation and jump to the element after it.
+if the block is not read fully all operations are added without execution until the block is closed
+(covers excludes look-ahead requirement). returns error outside of block
+*/
+func (s *Stack) SkipBlock() error {
+	if s.current == nil || s.current.CurrentLoop() == nil {
+		return errors.New("operation is not in a block")
+	}
+	loop := s.current.CurrentLoop()
+	s.nextElement = loop.Next()
+	for l := s.currentLoop; l != nil; l = l.GetPreviousLoop() {
+		if l == loop {
+			s.skip = loop
+		}
 	}
+	return nil
 }
 
 func (s *Stack) validateExecution() error {
@@ -1 +1,61 @@
 package stack
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestStack_Depth(t *testing.T) {
+	ctx := NewContextWithMemorySize(nil, bytes.NewBuffer(nil), 5)
+	depths := make(map[Position]int)
+	ctx.BeforeAction = func(c *Context) error {
+		depths[c.Stack.Current().Position()] = c.Stack.Depth()
+		return nil
+	}
+	for i, token := range []byte("+[[-]>+<]") {
+		var operation ExternalOperation
+		for _, o := range GetDefaultOperations() {
+			if o.Command() == Command(token) {
+				operation = o
+			}
+		}
+		if err := ctx.ExecuteAt(operation, Position{Offset: i}); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	expected := map[int]int{0: 0, 1: 1, 2: 2, 3: 2, 4: 2, 5: 1, 6: 1, 7: 1, 8: 1}
+	for offset, depth := range expected {
+		if depths[Position{Offset: offset}] != depth {
+			t.Errorf("wrong depth of operation at %v expected %v but was %v", offset, depth, depths[Position{Offset: offset}])
+		}
+	}
+	if err := ctx.ValidateExecution(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestStack_SkippedLoopIsNotExecuted(t *testing.T) {
+	ctx := NewContextWithMemorySize(nil, bytes.NewBuffer(nil), 5)
+	var executed []int
+	ctx.BeforeAction = func(c *Context) error {
+		executed = append(executed, c.Stack.Current().Position().Offset)
+		return nil
+	}
+	for i, token := range []byte("[+[-]]+") {
+		var operation ExternalOperation
+		for _, o := range GetDefaultOperations() {
+			if o.Command() == Command(token) {
+				operation = o
+			}
+		}
+		if err := ctx.ExecuteAt(operation, Position{Offset: i}); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	if len(executed) != 2 || executed[0] != 0 || executed[1] != 6 {
+		t.Fatalf("only loop start and last increment should be executed but was %v", executed)
+	}
+	if ctx.GetCurrentByte() != 1 {
+		t.Fatalf("wrong byte value should be 1 but was %v", ctx.GetCurrentByte())
+	}
+}
@@ -0,0 +1,237 @@
+package stack
+
+import (
+	"errors"
+	"fmt"
+)
+
+//ElementState describes operation or loop kept by the stack
+type ElementState struct {
+	//command of the operation. empty for loop
+	Command Command `json:"command,omitempty"`
+	//position of the operation
+	Position Position `json:"position"`
+	//true if element is a loop
+	Loop bool `json:"loop,omitempty"`
+	//elements of the loop
+	Elements []ElementState `json:"elements,omitempty"`
+	//number of the operation which opened the loop, for example '[' or block operation. zero means the first element
+	Opener int `json:"opener,omitempty"`
+}
+
+/*
+State contains execution state of the context: memory, pointer and stack elements needed to continue execution.
+elements are referenced by numbers in depth first order starting from 1, zero means no element
+*/
+type State struct {
+	Memory  []byte `json:"memory"`
+	Pointer int    `json:"pointer"`
+	//memory settings of the context
+	CellWidth int              `json:"cellWidth,omitempty"`
+	EOF       EOFBehavior      `json:"eof,omitempty"`
+	Grow      bool             `json:"grow,omitempty"`
+	Overflow  OverflowBehavior `json:"overflow,omitempty"`
+	//elements starting from the oldest top level element still needed for execution
+	Elements    []ElementState `json:"elements,omitempty"`
+	Next        int            `json:"next,omitempty"`
+	Current     int            `json:"current,omitempty"`
+	CurrentLoop int            `json:"currentLoop,omitempty"`
+	Skip        int            `json:"skip,omitempty"`
+	LastAdded   int            `json:"lastAdded,omitempty"`
+	//position of the last operation added to the stack
+	LastPosition Position `json:"lastPosition"`
+}
+
+//returns loop enclosing the element. returns nil for top level element
+func parentLoop(element LinkedElement) LoopElement {
+	switch e := element.(type) {
+	case *OperationContainer:
+		return e.loop
+	case *LoopContainer:
+		return e.parent
+	}
+	return nil
+}
+
+//returns top level element which contains provided element
+func topLevel(element LinkedElement) LinkedElement {
+	for loop := parentLoop(element); loop != nil; loop = parentLoop(element) {
+		element = loop
+	}
+	return element
+}
+
+/*
+returns state of the context. it is consistent between operations, for example after execution is suspended
+*/
+func (c *Context) State() State {
+	s := c.Stack
+	state := State{
+		Memory:       append([]byte(nil), c.Memory...),
+		Pointer:      c.CurrentIdx,
+		CellWidth:    c.CellWidth,
+		EOF:          c.EOF,
+		Grow:         c.Grow,
+		Overflow:     c.Overflow,
+		LastPosition: s.lastPosition,
+	}
+	roots := []LinkedElement{s.nextElement, s.current, s.currentLoop, s.skip, s.lastAdded}
+	candidates := make(map[LinkedElement]bool)
+	for _, root := range roots {
+		if root != nil {
+			candidates[topLevel(root)] = true
+		}
+	}
+	//the oldest top level element reaches all the others
+	var first LinkedElement
+	for candidate := range candidates {
+		found := 0
+		for e := candidate; e != nil; e = e.Next() {
+			if candidates[e] {
+				found++
+			}
+		}
+		if found == len(candidates) {
+			first = candidate
+		}
+	}
+	ids := make(map[LinkedElement]int)
+	state.Elements = describe(first, ids)
+	state.Next, state.Current, state.CurrentLoop = ids[s.nextElement], ids[s.current], ids[s.currentLoop]
+	state.Skip, state.LastAdded = ids[s.skip], ids[s.lastAdded]
+	return state
+}
+
+//describe elements starting from provided one and assign them numbers
+func describe(element LinkedElement, ids map[LinkedElement]int) []ElementState {
+	var result []ElementState
+	for e := element; e != nil; e = e.Next() {
+		ids[e] = len(ids) + 1
+		switch v := e.(type) {
+		case *OperationContainer:
+			result = append(result, ElementState{Command: v.operation.Command(), Position: v.position})
+		case *LoopContainer:
+			elements := describe(v.firstLoopElement, ids)
+			result = append(result, ElementState{Loop: true, Elements: elements, Opener: ids[v.firstLoopElement]})
+		}
+	}
+	return result
+}
+
+/*
+restore state of the context. operation returns registered operation by command.
+hooks, reader and writer of the context are kept
+*/
+func (c *Context) Restore(state State, operation func(Command) (ExternalOperation, bool)) error {
+	if len(state.Memory) == 0 {
+		return errors.New("memory is empty")
+	}
+	width := state.CellWidth
+	if width == 0 {
+		width = 1
+	}
+	if width != 1 && width != 2 && width != 4 || len(state.Memory)%width != 0 {
+		return fmt.Errorf("unsupported cell width %d", state.CellWidth)
+	}
+	if state.Pointer < 0 || state.Pointer >= len(state.Memory)/width {
+		return errors.New("pointer is out of range")
+	}
+	var elements []LinkedElement
+	if _, err := build(state.Elements, nil, &elements, operation); err != nil {
+		return err
+	}
+	element := func(id int) (LinkedElement, error) {
+		if id < 0 || id > len(elements) {
+			return nil, fmt.Errorf("unknown element %d", id)
+		}
+		if id == 0 {
+			return nil, nil
+		}
+		return elements[id-1], nil
+	}
+	loop := func(id int) (LoopElement, error) {
+		e, err := element(id)
+		if err != nil || e == nil {
+			return nil, err
+		}
+		l, ok := e.(*LoopContainer)
+		if !ok {
+			return nil, fmt.Errorf("element %d is not a loop", id)
+		}
+		return l, nil
+	}
+	s := &Stack{lastPosition: state.LastPosition}
+	var err error
+	if s.nextElement, err = element(state.Next); err != nil {
+		return err
+	}
+	if s.current, err = element(state.Current); err != nil {
+		return err
+	}
+	if s.lastAdded, err = element(state.LastAdded); err != nil {
+		return err
+	}
+	if s.currentLoop, err = loop(state.CurrentLoop); err != nil {
+		return err
+	}
+	if s.skip, err = loop(state.Skip); err != nil {
+		return err
+	}
+	c.Memory = append([]byte(nil), state.Memory...)
+	c.CurrentIdx = state.Pointer
+	c.CellWidth, c.EOF, c.Grow, c.Overflow = width, state.EOF, state.Grow, state.Overflow
+	c.Stack = s
+	return nil
+}
+
+//create linked elements of the list. created elements are appended in depth first order
+func build(states []ElementState, parent *LoopContainer, elements *[]LinkedElement,
+	operation func(Command) (ExternalOperation, bool)) (LinkedElement, error) {
+	var first, previous LinkedElement
+	for _, state := range states {
+		var e LinkedElement
+		if state.Loop {
+			loop := &LoopContainer{}
+			if parent != nil {
+				loop.parent = parent
+			}
+			*elements = append(*elements, loop)
+			id := len(*elements)
+			body, err := build(state.Elements, loop, elements, operation)
+			if err != nil {
+				return nil, err
+			}
+			opener := body
+			if state.Opener != 0 {
+				if state.Opener <= id || state.Opener > len(*elements) {
+					return nil, fmt.Errorf("unknown opener %d of loop %d", state.Opener, id)
+				}
+				opener = (*elements)[state.Opener-1]
+			}
+			op, ok := opener.(*OperationContainer)
+			if !ok || op.loop != loop {
+				return nil, fmt.Errorf("loop %d should be opened by its operation", id)
+			}
+			loop.firstLoopElement = op
+			e = loop
+		} else {
+			o, found := operation(state.Command)
+			if !found {
+				return nil, fmt.Errorf("unknown command %q", state.Command)
+			}
+			op := &OperationContainer{operation: o, position: state.Position}
+			if parent != nil {
+				op.loop = parent
+			}
+			*elements = append(*elements, op)
+			e = op
+		}
+		if previous != nil {
+			linkPrevious(previous, e)
+		} else {
+			first = e
+		}
+		previous = e
+	}
+	return first, nil
+}
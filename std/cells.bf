@@ -0,0 +1,13 @@
+#ifndef STD_CELLS
+#define STD_CELLS
+# cell helpers
+#define ZERO [-]
+#define ADD(n) {+}*n
+#define SUB(n) {-}*n
+#define RIGHT(n) {>}*n
+#define LEFT(n) {<}*n
+# move the current cell to the next one
+#define MOVE [->+<]
+# copy the current cell to the next one using the second one as temporary cell
+#define COPY [->+>+<<]>>[-<<+>>]<<
+#endif
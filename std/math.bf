@@ -0,0 +1,6 @@
+#ifndef STD_MATH
+#define STD_MATH
+# multiply the current cell by the next one
+# the result is stored in the current cell and the next three cells are zero after it
+#define MULTIPLY [>[->+>+<<]>>[-<<+>>]<<<-]>[-]>[-<<+>>]<<
+#endif
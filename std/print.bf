@@ -0,0 +1,8 @@
+#ifndef STD_PRINT
+#define STD_PRINT
+# print the current cell as decimal number
+# nine cells to the right are used as temporary cells and must be zero
+#define PRINT_DECIMAL >>++++++++++<<[->+>-[>+>>]>[+[-<+>]>+>>]<<<<<<]>>[-]>>>++++++++++<[->-[>+>>]>[+[-<+>]>+>>]<<<<<]>[-]>>[>++++++[-<++++++++>]<.<<+>+>[-]]<[<[->-<]++++++[->++++++++<]>.[-]]<<++++++[-<++++++++>]<.[-]<<[-<+>]<
+# print the current cell as single digit
+#define PRINT_DIGIT {+}*48.{-}*48
+#endif
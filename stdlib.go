@@ -0,0 +1,12 @@
+package compiler
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed std
+var stdlib embed.FS
+
+//Stdlib contains snippets of the standard library included as "std/name.bf". it is searched after the include path
+var Stdlib fs.FS = stdlib
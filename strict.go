@@ -0,0 +1,88 @@
+package compiler
+
+import (
+	"fmt"
+	"github.com/gdtrp/brainfuck/stack"
+	"io"
+	"strings"
+)
+
+//SyntaxError describes unknown character found in strict mode
+type SyntaxError struct {
+	stack.Position
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Position, e.Message)
+}
+
+/*
+enable or disable strict mode. in strict mode every byte which is not a command, whitespace or a part of comment
+stops execution with SyntaxError. comments are markers of comments lasting to the end of line, for example "#" or "//".
+markers can't start with a command. compilers copied before the call are not changed
+*/
+func (c *Compiler) SetStrict(enabled bool, comments ...string) error {
+	for _, comment := range comments {
+		if comment == "" || c.IsCommand(stack.Command(comment[0])) {
+			return fmt.Errorf("wrong comment marker %q", comment)
+		}
+	}
+	c.strict = enabled
+	c.comments = append([]string(nil), comments...)
+	return nil
+}
+
+//returns scanner of the script which checks syntax in strict mode
+func (c Compiler) scan(script io.Reader) *scanner {
+	s := newScanner(script)
+	if c.strict {
+		s.checker = &strictChecker{compiler: c}
+	}
+	return s
+}
+
+//strictChecker checks bytes read by scanner in strict mode
+type strictChecker struct {
+	compiler Compiler
+	//comment is being read
+	comment bool
+	//bytes of comment marker being read with position of the first one
+	marker []byte
+	start  stack.Position
+}
+
+//check byte read by scanner. returns true if the byte is a part of comment and should be skipped
+func (s *strictChecker) check(token byte, position stack.Position) (bool, error) {
+	if s.comment {
+		s.comment = token != '\n'
+		return true, nil
+	}
+	if len(s.marker) == 0 {
+		if s.compiler.IsCommand(stack.Command(token)) || isSpace(token) {
+			return false, nil
+		}
+		s.start = position
+	}
+	s.marker = append(s.marker, token)
+	prefix := false
+	for _, comment := range s.compiler.comments {
+		if comment == string(s.marker) {
+			s.comment, s.marker = true, nil
+			return true, nil
+		}
+		prefix = prefix || strings.HasPrefix(comment, string(s.marker))
+	}
+	if !prefix {
+		return false, &SyntaxError{Position: s.start, Message: fmt.Sprintf("unknown character %q", s.marker[0])}
+	}
+	return true, nil
+}
+
+//check the end of the script
+func (s *strictChecker) end() error {
+	if len(s.marker) > 0 {
+		return &SyntaxError{Position: s.start, Message: fmt.Sprintf("unknown character %q", s.marker[0])}
+	}
+	return nil
+}
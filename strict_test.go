@@ -0,0 +1,59 @@
+package compiler
+
+import (
+	"bytes"
+	"github.com/gdtrp/brainfuck/stack"
+	"strings"
+	"testing"
+)
+
+func TestCompiler_Strict(t *testing.T) {
+	c, _ := New()
+	if err := c.SetStrict(true, "#", "//"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	for _, test := range []struct {
+		script string
+		error  string
+	}{
+		{"++ # add two\n\t.// print\r\n", ""},
+		{"+\n +x", "2:3: unknown character 'x'"},
+		{"+/+", "1:2: unknown character '/'"},
+		{"+ /", "1:3: unknown character '/'"},
+		{"//.\n+", ""},
+	} {
+		err := c.Compile(strings.NewReader(test.script), nil, &bytes.Buffer{})
+		if test.error == "" && err != nil {
+			t.Fatalf("%q: unexpected error %v", test.script, err)
+		}
+		if _, ok := err.(*SyntaxError); test.error != "" && (!ok || err.Error() != test.error) {
+			t.Fatalf("%q: error %q expected but was %v", test.script, test.error, err)
+		}
+	}
+	var output bytes.Buffer
+	if err := c.Compile(strings.NewReader("+#.\n."), nil, &output); err != nil || !bytes.Equal(output.Bytes(), []byte{1}) {
+		t.Fatalf("commands in comments should be ignored but was %v %v", output.Bytes(), err)
+	}
+	if err := c.SetStrict(true, "--"); err == nil {
+		t.Fatalf("error expected for comment marker starting with command")
+	}
+	c.SetStrict(false)
+	if err := c.Compile(strings.NewReader("x+"), nil, &bytes.Buffer{}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestCompiler_Tokens(t *testing.T) {
+	c, _ := New()
+	c.SetStrict(true, "#")
+	tokens, err := c.Tokens(strings.NewReader("#!bf cells=8\n+ # print.\n."))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(tokens) != 2 || tokens[0].Command != "+" || tokens[1].Position != (stack.Position{Offset: 24, Line: 3, Column: 1}) {
+		t.Fatalf("wrong tokens %v", tokens)
+	}
+	if _, err := c.Tokens(strings.NewReader("+x")); err == nil {
+		t.Fatalf("syntax error expected")
+	}
+}
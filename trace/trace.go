@@ -0,0 +1,252 @@
+/*
+Package trace records execution of brainfuck scripts as JSON Lines.
+
+Recorder writes one JSON object per executed operation. Sampling and start/stop window options keep traces of
+long running scripts manageable. Reader loads recorded traces back.
+*/
+package trace
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	compiler "github.com/gdtrp/brainfuck"
+	"github.com/gdtrp/brainfuck/stack"
+	"io"
+	"strings"
+)
+
+//Bytes is encoded as array of numbers instead of base64 string
+type Bytes []byte
+
+func (b Bytes) MarshalJSON() ([]byte, error) {
+	if b == nil {
+		return []byte("null"), nil
+	}
+	values := make([]string, len(b))
+	for i, v := range b {
+		values[i] = fmt.Sprint(v)
+	}
+	return []byte("[" + strings.Join(values, ",") + "]"), nil
+}
+
+func (b *Bytes) UnmarshalJSON(data []byte) error {
+	var values []byte
+	var numbers []int
+	if err := json.Unmarshal(data, &numbers); err != nil {
+		return err
+	}
+	for _, n := range numbers {
+		if n < 0 || n > 255 {
+			return fmt.Errorf("byte value %v is out of range", n)
+		}
+		values = append(values, byte(n))
+	}
+	*b = values
+	return nil
+}
+
+//Record describes single executed operation
+type Record struct {
+	//number of executed operation starting from 1
+	Step int `json:"step"`
+	stack.Position
+	Token stack.Command `json:"token"`
+	//memory cell index before the operation
+	Pointer int `json:"pointer"`
+	//current cell value before the operation
+	Before uint32 `json:"before"`
+	//current cell value after the operation. pointer can be changed by the operation
+	After uint32 `json:"after"`
+	//bytes read from the input by the operation
+	Read Bytes `json:"read,omitempty"`
+	//bytes written to the output by the operation
+	Written Bytes `json:"written,omitempty"`
+}
+
+//Options limits recorded operations
+type Options struct {
+	//record every n-th operation of the window. zero and one record all operations
+	Every int
+	//first recorded step. zero starts from the first step
+	Start int
+	//last recorded step. zero records until the end of the script
+	Stop int
+}
+
+//records returns true if the step should be recorded
+func (o Options) records(step int) bool {
+	if step < o.Start || (o.Stop > 0 && step > o.Stop) {
+		return false
+	}
+	first := o.Start
+	if first < 1 {
+		first = 1
+	}
+	return o.Every <= 1 || (step-first)%o.Every == 0
+}
+
+//Recorder writes executed operations as JSON Lines
+type Recorder struct {
+	compiler compiler.Compiler
+	options  Options
+	writer   *bufio.Writer
+	encoder  *json.Encoder
+	step     int
+	//record of currently executed operation. nil if the operation is not recorded
+	current *Record
+}
+
+/*
+create new recorder writing records to writer
+*/
+func New(c compiler.Compiler, writer io.Writer, options Options) *Recorder {
+	w := bufio.NewWriter(writer)
+	return &Recorder{compiler: c, options: options, writer: w, encoder: json.NewEncoder(w)}
+}
+
+/*
+compile provided script recording executed operations. read byte data from reader and write outgoing bytes to writer.
+memory is configured by the compiler profile
+*/
+func (r *Recorder) Compile(script io.Reader, reader io.Reader, writer io.Writer) error {
+	return r.Run(script, r.compiler.NewContext(reader, writer))
+}
+
+/*
+compile provided script using prepared context recording executed operations
+*/
+func (r *Recorder) Run(script io.Reader, context *stack.Context) error {
+	r.Attach(context)
+	err := r.compiler.Run(script, context)
+	if flushErr := r.Flush(); err == nil {
+		err = flushErr
+	}
+	return err
+}
+
+/*
+record operations executed with context. allows to combine recorder with other tools running the script.
+Flush should be called after the execution
+*/
+func (r *Recorder) Attach(context *stack.Context) {
+	if context.Reader != nil {
+		context.Reader = &inputRecorder{reader: context.Reader, recorder: r}
+	}
+	if context.Writer != nil {
+		context.Writer = &outputRecorder{writer: context.Writer, recorder: r}
+	}
+	before, after := context.BeforeAction, context.AfterAction
+	context.BeforeAction = func(ctx *stack.Context) error {
+		if before != nil {
+			if err := before(ctx); err != nil {
+				return err
+			}
+		}
+		r.before(ctx)
+		return nil
+	}
+	context.AfterAction = func(ctx *stack.Context) error {
+		if err := r.after(ctx); err != nil {
+			return err
+		}
+		if after != nil {
+			return after(ctx)
+		}
+		return nil
+	}
+}
+
+//write buffered records
+func (r *Recorder) Flush() error {
+	return r.writer.Flush()
+}
+
+func (r *Recorder) before(ctx *stack.Context) {
+	r.step++
+	r.current = nil
+	if !r.options.records(r.step) {
+		return
+	}
+	element := ctx.Stack.Current()
+	r.current = &Record{
+		Step:     r.step,
+		Position: element.Position(),
+		Token:    element.Operation().Command(),
+		Pointer:  ctx.GetIndex(),
+		Before:   ctx.GetCurrentCell(),
+	}
+}
+
+func (r *Recorder) after(ctx *stack.Context) error {
+	if r.current == nil {
+		return nil
+	}
+	r.current.After = ctx.GetCurrentCell()
+	err := r.encoder.Encode(r.current)
+	r.current = nil
+	return err
+}
+
+//inputRecorder adds bytes read by recorded operation to its record
+type inputRecorder struct {
+	reader   io.Reader
+	recorder *Recorder
+}
+
+func (i *inputRecorder) Read(p []byte) (int, error) {
+	n, err := i.reader.Read(p)
+	if current := i.recorder.current; current != nil && n > 0 {
+		current.Read = append(current.Read, p[:n]...)
+	}
+	return n, err
+}
+
+//outputRecorder adds bytes written by recorded operation to its record
+type outputRecorder struct {
+	writer   io.Writer
+	recorder *Recorder
+}
+
+func (o *outputRecorder) Write(p []byte) (int, error) {
+	n, err := o.writer.Write(p)
+	if current := o.recorder.current; current != nil && n > 0 {
+		current.Written = append(current.Written, p[:n]...)
+	}
+	return n, err
+}
+
+//Reader loads records written by Recorder
+type Reader struct {
+	decoder *json.Decoder
+}
+
+/*
+create new trace reader
+*/
+func NewReader(reader io.Reader) *Reader {
+	return &Reader{decoder: json.NewDecoder(reader)}
+}
+
+//read next record. returns io.EOF after the last record
+func (r *Reader) Next() (Record, error) {
+	var record Record
+	err := r.decoder.Decode(&record)
+	return record, err
+}
+
+//read all records of the trace
+func ReadAll(reader io.Reader) ([]Record, error) {
+	r := NewReader(reader)
+	var records []Record
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, record)
+	}
+}
@@ -0,0 +1,80 @@
+package trace
+
+import (
+	"bytes"
+	compiler "github.com/gdtrp/brainfuck"
+	"github.com/gdtrp/brainfuck/stack"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func record(t *testing.T, script string, input string, options Options) (string, []Record) {
+	c, _ := compiler.New()
+	var buf bytes.Buffer
+	if err := New(c, &buf, options).Compile(strings.NewReader(script), strings.NewReader(input), ioutil.Discard); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	records, err := ReadAll(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	return buf.String(), records
+}
+
+func TestRecorder_Compile(t *testing.T) {
+	text, records := record(t, ",>+\n.", "A", Options{})
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("four records expected but was %v", text)
+	}
+	expected := `{"step":1,"offset":0,"line":1,"column":1,"token":",","pointer":0,"before":0,"after":65,"read":[65]}`
+	if lines[0] != expected {
+		t.Fatalf("wrong record expected %v but was %v", expected, lines[0])
+	}
+	last := records[3]
+	if last.Step != 4 || last.Position != (stack.Position{Offset: 4, Line: 2, Column: 1}) || last.Token != "." ||
+		last.Pointer != 1 || last.Before != 1 || last.After != 1 || !bytes.Equal(last.Written, []byte{1}) || last.Read != nil {
+		t.Fatalf("wrong record %v", last)
+	}
+}
+
+func TestRecorder_Window(t *testing.T) {
+	_, records := record(t, "+++[-]", "", Options{Every: 2, Start: 3, Stop: 8})
+	var steps []int
+	for _, r := range records {
+		steps = append(steps, r.Step)
+	}
+	if len(steps) != 3 || steps[0] != 3 || steps[1] != 5 || steps[2] != 7 {
+		t.Fatalf("wrong recorded steps %v", steps)
+	}
+	if records[1].Token != "-" || records[1].Before != 3 || records[1].After != 2 {
+		t.Fatalf("wrong record %v", records[1])
+	}
+}
+
+func TestRecorder_WideCells(t *testing.T) {
+	_, records := record(t, "#!bf cells=16\n-", "", Options{})
+	if len(records) != 1 || records[0].Before != 0 || records[0].After != 0xffff {
+		t.Fatalf("wrong records %v", records)
+	}
+}
+
+func TestRecorder_Profile(t *testing.T) {
+	wide, _ := compiler.LookupProfile(compiler.ProfileWide)
+	c, _ := compiler.New()
+	c.SetProfile(wide)
+	var buf bytes.Buffer
+	if err := New(c, &buf, Options{}).Compile(strings.NewReader("-"), strings.NewReader(""), ioutil.Discard); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if records, _ := ReadAll(&buf); len(records) != 1 || records[0].After != 0xffff {
+		t.Fatalf("wrong records %v", records)
+	}
+}
+
+func TestReader_Error(t *testing.T) {
+	if _, err := ReadAll(strings.NewReader(`{"step":1,"read":[300]}`)); err == nil {
+		t.Fatalf("error expected for out of range byte")
+	}
+}
@@ -0,0 +1,242 @@
+/*
+Package web serves brainfuck scripts as HTTP endpoints.
+
+Handler runs the script for every request with the request body as input and returns the output as the response body.
+Execution of every request is limited by time, number of executed operations and size of the output. Operations created by
+HeaderOperation and QueryOperation read request headers and query parameters.
+*/
+package web
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	compiler "github.com/gdtrp/brainfuck"
+	"github.com/gdtrp/brainfuck/stack"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+//ErrStepLimit stops execution when the number of executed operations exceeds the limit
+var ErrStepLimit = errors.New("step limit exceeded")
+
+//ErrOutputLimit stops execution when the script writes more bytes than the limit
+var ErrOutputLimit = errors.New("output limit exceeded")
+
+//limits used when options are zero
+const (
+	DefaultTimeout   = 10 * time.Second
+	DefaultMaxSteps  = 100000000
+	DefaultMaxOutput = 1 << 20
+)
+
+//Options limits execution of the script per request
+type Options struct {
+	//maximal duration of execution. zero means DefaultTimeout, negative value means no limit
+	Timeout time.Duration
+	//maximal number of executed operations. zero means DefaultMaxSteps, negative value means no limit
+	MaxSteps int
+	//maximal number of bytes written by the script. zero means DefaultMaxOutput, negative value means no limit
+	MaxOutput int
+	//content type of the response. application/octet-stream is used if it is empty
+	ContentType string
+}
+
+//Handler runs the script for every request
+type Handler struct {
+	compiler compiler.Compiler
+	script   []byte
+	options  Options
+}
+
+/*
+create new handler of the script. script is read and checked for unbalanced brackets once,
+it is executed by the compiler for every request
+*/
+func New(c compiler.Compiler, script io.Reader, options Options) (*Handler, error) {
+	source, err := ioutil.ReadAll(script)
+	if err != nil {
+		return nil, err
+	}
+	diagnostics, err := c.Lint(bytes.NewReader(source))
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range diagnostics {
+		if d.Rule == compiler.LintUnbalanced {
+			return nil, fmt.Errorf("%v: %v", d.Position, d.Message)
+		}
+	}
+	if options.ContentType == "" {
+		options.ContentType = "application/octet-stream"
+	}
+	if options.Timeout == 0 {
+		options.Timeout = DefaultTimeout
+	}
+	if options.MaxSteps == 0 {
+		options.MaxSteps = DefaultMaxSteps
+	}
+	if options.MaxOutput == 0 {
+		options.MaxOutput = DefaultMaxOutput
+	}
+	return &Handler{compiler: c, script: source, options: options}, nil
+}
+
+/*
+run the script with request body as input. output is sent with status 200 after the script is finished.
+status 503 is sent if execution is timed out or the request is cancelled and status 500 is sent for other errors
+*/
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	output := &limitedWriter{limit: h.options.MaxOutput}
+	//reason is set before stopped is closed
+	var reason string
+	stopped := make(chan struct{})
+	ctx := h.compiler.NewContext(&body{reader: r.Body, stopped: stopped}, output)
+	ctx.Value = &request{request: r, offsets: make(map[stack.Command]int)}
+	steps := 0
+	ctx.AfterAction = func(ctx *stack.Context) error {
+		if steps++; h.options.MaxSteps >= 0 && steps > h.options.MaxSteps {
+			return ErrStepLimit
+		}
+		return nil
+	}
+	done := make(chan struct{})
+	defer close(done)
+	var deadline <-chan time.Time
+	if h.options.Timeout >= 0 {
+		timer := time.NewTimer(h.options.Timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	go func() {
+		select {
+		case <-deadline:
+			reason = "execution timeout"
+		case <-r.Context().Done():
+			reason = "request cancelled"
+		case <-done:
+			return
+		}
+		close(stopped)
+		ctx.Suspend()
+	}()
+	err := h.compiler.Run(bytes.NewReader(h.script), ctx)
+	switch {
+	case err == stack.ErrSuspended || err == errStopped:
+		<-stopped
+		http.Error(w, reason, http.StatusServiceUnavailable)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		w.Header().Set("Content-Type", h.options.ContentType)
+		w.Write(output.buffer.Bytes())
+	}
+}
+
+//limitedWriter collects output of the script up to the limit. negative limit means no limit
+type limitedWriter struct {
+	buffer bytes.Buffer
+	limit  int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.limit >= 0 && w.buffer.Len()+len(p) > w.limit {
+		return 0, ErrOutputLimit
+	}
+	return w.buffer.Write(p)
+}
+
+//errStopped is returned by reading of the request body after execution is timed out or the request is cancelled
+var errStopped = errors.New("execution stopped")
+
+//body is the request body which stops blocked reading when execution is stopped
+type body struct {
+	reader  io.Reader
+	stopped <-chan struct{}
+	//result of the reading which is not finished yet
+	pending chan result
+}
+
+type result struct {
+	data []byte
+	err  error
+}
+
+func (b *body) Read(p []byte) (int, error) {
+	select {
+	case <-b.stopped:
+		return 0, errStopped
+	default:
+	}
+	if b.pending == nil {
+		pending := make(chan result, 1)
+		//own buffer is used, the reading can finish after the call is returned
+		go func(data []byte) {
+			n, err := b.reader.Read(data)
+			pending <- result{data: data[:n], err: err}
+		}(make([]byte, len(p)))
+		b.pending = pending
+	}
+	select {
+	case res := <-b.pending:
+		b.pending = nil
+		return copy(p, res.data), res.err
+	case <-b.stopped:
+		return 0, errStopped
+	}
+}
+
+//request is the request being handled with read offsets of request operations. it is the value of the running context
+type request struct {
+	request *http.Request
+	offsets map[stack.Command]int
+}
+
+//operation reads value of the request
+type operation struct {
+	command stack.Command
+	value   func(r *http.Request) string
+}
+
+func (o operation) Command() stack.Command {
+	return o.command
+}
+
+func (o operation) Action() func(*stack.Context) error {
+	return func(ctx *stack.Context) error {
+		request, found := ctx.Value.(*request)
+		if !found {
+			return errors.New("operation " + string(o.command) + " is executed outside of http request")
+		}
+		value := o.value(request.request)
+		offset := request.offsets[o.command]
+		if offset == len(value) {
+			request.offsets[o.command] = 0
+			return ctx.SetCurrentCell(0)
+		}
+		request.offsets[o.command] = offset + 1
+		return ctx.SetCurrentCell(uint32(value[offset]))
+	}
+}
+
+/*
+returns operation reading the next byte of the request header value into the current cell. zero is stored
+after the last byte and the value is read from the beginning again
+*/
+func HeaderOperation(command stack.Command, name string) stack.ExternalOperation {
+	return operation{command: command, value: func(r *http.Request) string {
+		return r.Header.Get(name)
+	}}
+}
+
+/*
+returns operation reading the next byte of the query parameter value into the current cell. zero is stored
+after the last byte and the value is read from the beginning again
+*/
+func QueryOperation(command stack.Command, name string) stack.ExternalOperation {
+	return operation{command: command, value: func(r *http.Request) string {
+		return r.URL.Query().Get(name)
+	}}
+}
@@ -0,0 +1,124 @@
+package web
+
+import (
+	"context"
+	compiler "github.com/gdtrp/brainfuck"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+//bytes from 255 to 1 written by "-[.-]"
+var reverse = func() []byte {
+	result := make([]byte, 255)
+	for i := range result {
+		result[i] = byte(255 - i)
+	}
+	return result
+}()
+
+func TestHandler(t *testing.T) {
+	c, _ := compiler.New(HeaderOperation("^", "X-Name"), QueryOperation("?", "name"))
+	for _, test := range []struct {
+		name        string
+		script      string
+		options     Options
+		target      string
+		body        string
+		status      int
+		contentType string
+		response    string
+	}{
+		{"echo", "#!bf eof=0\n,[.,]", Options{ContentType: "text/plain"}, "/", "hello", 200, "text/plain", "hello"},
+		{"default content type", "+++.", Options{}, "/", "", 200, "application/octet-stream", "\x03"},
+		{"header", "^[.^]", Options{}, "/", "", 200, "application/octet-stream", "bf"},
+		{"query", "?[.?]?[.?]", Options{}, "/?name=q", "", 200, "application/octet-stream", "qq"},
+		{"script error", "+.<", Options{}, "/", "", 500, "text/plain; charset=utf-8", "index is out of range\n"},
+		{"step limit", "+[]", Options{MaxSteps: 100}, "/", "", 500, "text/plain; charset=utf-8", "step limit exceeded\n"},
+		{"timeout", "+[]", Options{Timeout: 10 * time.Millisecond}, "/", "", 503, "text/plain; charset=utf-8", "execution timeout\n"},
+		{"output limit", "+[.]", Options{MaxOutput: 3}, "/", "", 500, "text/plain; charset=utf-8", "output limit exceeded\n"},
+		{"no output limit", "-[.-]", Options{MaxOutput: -1}, "/", "", 200, "application/octet-stream", string(reverse)},
+	} {
+		h, err := New(c, strings.NewReader(test.script), test.options)
+		if err != nil {
+			t.Fatalf("%v: unexpected error %v", test.name, err)
+		}
+		server := httptest.NewServer(h)
+		request, _ := http.NewRequest("POST", server.URL+test.target, strings.NewReader(test.body))
+		request.Header.Set("X-Name", "bf")
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("%v: unexpected error %v", test.name, err)
+		}
+		body, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		server.Close()
+		if response.StatusCode != test.status || string(body) != test.response {
+			t.Fatalf("%v: expected %v %q but was %v %q", test.name, test.status, test.response, response.StatusCode, body)
+		}
+		if contentType := response.Header.Get("Content-Type"); contentType != test.contentType {
+			t.Fatalf("%v: wrong content type %v", test.name, contentType)
+		}
+	}
+}
+
+func TestHandler_Recorder(t *testing.T) {
+	c, _ := compiler.New()
+	h, _ := New(c, strings.NewReader("#!bf eof=0\n,[+.,]"), Options{})
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest("POST", "/", strings.NewReader("HAL")))
+	if recorder.Code != 200 || recorder.Body.String() != "IBM" {
+		t.Fatalf("wrong response %v %q", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestHandler_BlockedInput(t *testing.T) {
+	c, _ := compiler.New()
+	h, _ := New(c, strings.NewReader(",."), Options{Timeout: 10 * time.Millisecond})
+	//body which never ends
+	input, _ := io.Pipe()
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest("POST", "/", input))
+	if recorder.Code != 503 || recorder.Body.String() != "execution timeout\n" {
+		t.Fatalf("wrong response %v %q", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestHandler_Cancelled(t *testing.T) {
+	c, _ := compiler.New()
+	h, _ := New(c, strings.NewReader(",."), Options{Timeout: time.Minute})
+	input, _ := io.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest("POST", "/", input).WithContext(ctx))
+	if recorder.Code != 503 || recorder.Body.String() != "request cancelled\n" {
+		t.Fatalf("wrong response %v %q", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestNew_DefaultLimits(t *testing.T) {
+	c, _ := compiler.New()
+	h, _ := New(c, strings.NewReader("+[]"), Options{})
+	if h.options.Timeout != DefaultTimeout || h.options.MaxSteps != DefaultMaxSteps || h.options.MaxOutput != DefaultMaxOutput {
+		t.Fatalf("default limits expected but was %+v", h.options)
+	}
+}
+
+func TestNew_Unbalanced(t *testing.T) {
+	c, _ := compiler.New()
+	if _, err := New(c, strings.NewReader("+\n+]"), Options{}); err == nil || err.Error() != "2:2: missing start loop" {
+		t.Fatalf("unbalanced brackets error expected but was %v", err)
+	}
+}
+
+func TestOperation_OutsideOfRequest(t *testing.T) {
+	c, _ := compiler.New(HeaderOperation("^", "X-Name"))
+	if err := c.Compile(strings.NewReader("^"), strings.NewReader(""), ioutil.Discard); err == nil {
+		t.Fatalf("error expected")
+	}
+}